@@ -0,0 +1,143 @@
+package controllers
+
+import (
+	"encoding/json"
+	"github.com/julienschmidt/httprouter"
+	"go.uber.org/zap"
+	"gomeow/cmd/models"
+	"gomeow/pkg/application"
+	"gomeow/pkg/middleware"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type apiKeyRequest struct {
+	Name         string     `json:"name"`
+	Scopes       []string   `json:"scopes"`
+	ExpiresAt    *time.Time `json:"expires_at"`
+	Signing      bool       `json:"signing"`
+	DailyQuota   int        `json:"daily_quota"`
+	MonthlyQuota int        `json:"monthly_quota"`
+}
+
+// issuedApiKey is the only response carrying the plain key and the signing secret.
+type issuedApiKey struct {
+	models.ApiKey
+	Key           string `json:"key"`
+	SigningSecret string `json:"signing_secret,omitempty"`
+}
+
+func ApiKeyIndex(app *application.Application) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		keys := []models.ApiKey{}
+		app.MessageStore.Order("id desc").Find(&keys)
+
+		writeJsonResponse(w, http.StatusOK, "API keys found", keys)
+	}
+}
+
+// ApiKeyStore issues a key. The key is returned once and can't be retrieved later.
+func ApiKeyStore(app *application.Application) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		defer r.Body.Close()
+
+		var requestData apiKeyRequest
+		if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+			writeErrorResponse(w, http.StatusUnprocessableEntity, "Unprocessable Entity")
+			return
+		}
+
+		requestData.Name = strings.TrimSpace(requestData.Name)
+		if len(requestData.Name) == 0 || len(requestData.Scopes) == 0 {
+			writeErrorResponse(w, http.StatusUnprocessableEntity, "Invalid Parameter Supplied")
+			return
+		}
+
+		for _, scope := range requestData.Scopes {
+			if !validScope(scope) {
+				writeErrorResponse(w, http.StatusUnprocessableEntity, "Invalid Scope "+scope)
+				return
+			}
+		}
+
+		if requestData.ExpiresAt != nil && !requestData.ExpiresAt.After(time.Now()) {
+			writeErrorResponse(w, http.StatusUnprocessableEntity, "Expiry Is In The Past")
+			return
+		}
+
+		if requestData.DailyQuota < 0 || requestData.MonthlyQuota < 0 {
+			writeErrorResponse(w, http.StatusUnprocessableEntity, "Invalid Quota")
+			return
+		}
+
+		key := models.ApiKey{
+			Name:         requestData.Name,
+			Scopes:       strings.Join(requestData.Scopes, ","),
+			ExpiresAt:    requestData.ExpiresAt,
+			Signing:      requestData.Signing,
+			DailyQuota:   requestData.DailyQuota,
+			MonthlyQuota: requestData.MonthlyQuota,
+		}
+
+		plain, err := app.Keys.Issue(&key)
+		if err != nil {
+			zap.S().Errorf(err.Error())
+			writeErrorResponse(w, http.StatusInternalServerError, "Internal Server Error")
+			return
+		}
+
+		writeJsonResponse(w, http.StatusCreated, "API key issued, store it now as it won't be shown again", issuedApiKey{key, plain, key.SigningSecret})
+	}
+}
+
+// ApiKeyDelete revokes a key. Revoked keys are kept, so that their usage can still be looked up.
+func ApiKeyDelete(app *application.Application) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		key := models.ApiKey{}
+		if app.MessageStore.Where("id = ?", p.ByName("id")).First(&key).RecordNotFound() {
+			writeErrorResponse(w, http.StatusNotFound, "API Key Not Found")
+			return
+		}
+
+		if key.RevokedAt == nil {
+			if err := app.Keys.Revoke(&key); err != nil {
+				zap.S().Errorf(err.Error())
+				writeErrorResponse(w, http.StatusInternalServerError, "Internal Server Error")
+				return
+			}
+		}
+
+		writeJsonResponse(w, http.StatusOK, "API key revoked", key)
+	}
+}
+
+// ApiKeyUsage returns the quota usage of a key.
+func ApiKeyUsage(app *application.Application) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		key := models.ApiKey{}
+		if app.MessageStore.Where("id = ?", p.ByName("id")).First(&key).RecordNotFound() {
+			writeErrorResponse(w, http.StatusNotFound, "API Key Not Found")
+			return
+		}
+
+		writeJsonResponse(w, http.StatusOK, "API key usage", app.Keys.Usage(&key))
+	}
+}
+
+// UsageShow returns the quota usage of the key the request has been made with.
+func UsageShow(app *application.Application) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		writeJsonResponse(w, http.StatusOK, "API key usage", app.Keys.Usage(middleware.ApiKey(r)))
+	}
+}
+
+func validScope(scope string) bool {
+	for _, s := range models.ApiScopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,281 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"go.uber.org/zap"
+	"gomeow/cmd/models"
+	"gomeow/pkg/application"
+	"gomeow/pkg/webhook"
+	"mime"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+type autoReplyMedia struct {
+	Data     string `json:"data"`
+	MimeType string `json:"mime_type"`
+	FileName string `json:"file_name"`
+}
+
+type autoReplyRequest struct {
+	Name            string          `json:"name"`
+	Priority        int             `json:"priority"`
+	MatchType       string          `json:"match_type"`
+	Pattern         string          `json:"pattern"`
+	Sender          string          `json:"sender"`
+	Group           string          `json:"group"`
+	TimeFrom        string          `json:"time_from"`
+	TimeTo          string          `json:"time_to"`
+	ReplyType       string          `json:"reply_type"`
+	ReplyText       string          `json:"reply_text"`
+	Media           *autoReplyMedia `json:"media"`
+	WebhookURL      string          `json:"webhook_url"`
+	CooldownSeconds int             `json:"cooldown_seconds"`
+	Active          *bool           `json:"active"`
+}
+
+func AutoReplyIndex(app *application.Application) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		rules := []models.AutoReplyRule{}
+		app.MessageStore.Order("priority desc, id").Find(&rules)
+
+		writeJsonResponse(w, http.StatusOK, "Auto reply rules found", rules)
+	}
+}
+
+func AutoReplyShow(app *application.Application) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		rule := models.AutoReplyRule{}
+		if app.MessageStore.Where("id = ?", p.ByName("id")).First(&rule).RecordNotFound() {
+			writeErrorResponse(w, http.StatusNotFound, "Auto Reply Rule Not Found")
+			return
+		}
+
+		writeJsonResponse(w, http.StatusOK, "Auto reply rule found", rule)
+	}
+}
+
+func AutoReplyStore(app *application.Application) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		rule := models.AutoReplyRule{}
+		if !fillAutoReplyRule(app, w, r, &rule) {
+			return
+		}
+
+		if err := app.MessageStore.Create(&rule).Error; err != nil {
+			zap.S().Errorf(err.Error())
+			deleteAutoReplyMedia(app, rule.MediaKey)
+			writeErrorResponse(w, http.StatusInternalServerError, "Internal Server Error")
+			return
+		}
+
+		writeJsonResponse(w, http.StatusCreated, "Auto reply rule created", rule)
+	}
+}
+
+func AutoReplyUpdate(app *application.Application) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		rule := models.AutoReplyRule{}
+		if app.MessageStore.Where("id = ?", p.ByName("id")).First(&rule).RecordNotFound() {
+			writeErrorResponse(w, http.StatusNotFound, "Auto Reply Rule Not Found")
+			return
+		}
+
+		previousMedia := rule.MediaKey
+		if !fillAutoReplyRule(app, w, r, &rule) {
+			return
+		}
+
+		if err := app.MessageStore.Save(&rule).Error; err != nil {
+			zap.S().Errorf(err.Error())
+			if rule.MediaKey != previousMedia {
+				deleteAutoReplyMedia(app, rule.MediaKey)
+			}
+			writeErrorResponse(w, http.StatusInternalServerError, "Internal Server Error")
+			return
+		}
+
+		// replaced or no longer used media
+		if rule.MediaKey != previousMedia {
+			deleteAutoReplyMedia(app, previousMedia)
+		}
+
+		writeJsonResponse(w, http.StatusOK, "Auto reply rule updated", rule)
+	}
+}
+
+func AutoReplyDelete(app *application.Application) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		rule := models.AutoReplyRule{}
+		if app.MessageStore.Where("id = ?", p.ByName("id")).First(&rule).RecordNotFound() {
+			writeErrorResponse(w, http.StatusNotFound, "Auto Reply Rule Not Found")
+			return
+		}
+
+		if err := app.MessageStore.Delete(&rule).Error; err != nil {
+			zap.S().Errorf(err.Error())
+			writeErrorResponse(w, http.StatusInternalServerError, "Internal Server Error")
+			return
+		}
+		deleteAutoReplyMedia(app, rule.MediaKey)
+
+		writeJsonResponse(w, http.StatusOK, "Auto reply rule deleted", rule)
+	}
+}
+
+// fillAutoReplyRule validates the request body into the rule, writing an error response when it is invalid.
+func fillAutoReplyRule(app *application.Application, w http.ResponseWriter, r *http.Request, rule *models.AutoReplyRule) bool {
+	defer r.Body.Close()
+
+	var requestData autoReplyRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSendRequestSize)).Decode(&requestData); err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			writeErrorResponse(w, http.StatusRequestEntityTooLarge, "Request Entity Too Large")
+			return false
+		}
+
+		writeErrorResponse(w, http.StatusUnprocessableEntity, "Unprocessable Entity")
+		return false
+	}
+
+	if err := validateAutoReplyRequest(requestData, len(rule.MediaKey) > 0); err != nil {
+		writeErrorResponse(w, http.StatusUnprocessableEntity, err.Error())
+		return false
+	}
+
+	rule.Name = requestData.Name
+	rule.Priority = requestData.Priority
+	rule.MatchType = requestData.MatchType
+	rule.Pattern = requestData.Pattern
+	rule.Sender = requestData.Sender
+	rule.Group = requestData.Group
+	rule.TimeFrom = requestData.TimeFrom
+	rule.TimeTo = requestData.TimeTo
+	rule.ReplyType = requestData.ReplyType
+	rule.ReplyText = requestData.ReplyText
+	rule.WebhookURL = requestData.WebhookURL
+	rule.CooldownSeconds = requestData.CooldownSeconds
+	rule.Active = requestData.Active == nil || *requestData.Active
+
+	// media of other reply types is dropped, and deleted once the rule is saved
+	if rule.ReplyType != models.AutoReplyTypeMedia {
+		rule.MediaKey = ""
+		rule.MimeType = ""
+		rule.FileName = ""
+	}
+
+	if requestData.Media != nil && rule.ReplyType == models.AutoReplyTypeMedia {
+		data, _ := base64.StdEncoding.DecodeString(requestData.Media.Data)
+		mimeType := requestData.Media.MimeType
+		if len(mimeType) == 0 {
+			mimeType = http.DetectContentType(data)
+		}
+
+		messageType := application.MediaMessageType(mimeType)
+		if limit := mediaSizeLimits[messageType]; len(data) > limit {
+			writeErrorResponse(w, http.StatusUnprocessableEntity, fmt.Sprintf("Media Exceeds The %d MB Limit Of %s Messages", limit>>20, messageType))
+			return false
+		}
+
+		extension := filepath.Ext(requestData.Media.FileName)
+		if len(extension) == 0 {
+			if extensions, _ := mime.ExtensionsByType(mimeType); len(extensions) > 0 {
+				extension = extensions[0]
+			}
+		}
+
+		key := fmt.Sprintf("autoreply/%s%s", webhook.GenerateSecret(16), extension)
+		if err := app.Storage.Put(key, mimeType, data); err != nil {
+			zap.S().Errorf("Failed to store auto reply media: %s", err)
+			writeErrorResponse(w, http.StatusInternalServerError, "Internal Server Error")
+			return false
+		}
+
+		rule.MediaKey = key
+		rule.MimeType = mimeType
+		rule.FileName = requestData.Media.FileName
+	}
+
+	return true
+}
+
+func deleteAutoReplyMedia(app *application.Application, key string) {
+	if len(key) == 0 {
+		return
+	}
+
+	if err := app.Storage.Delete(key); err != nil {
+		zap.S().Errorf("Failed to delete auto reply media %s: %s", key, err)
+	}
+}
+
+func validateAutoReplyRequest(requestData autoReplyRequest, hasMedia bool) error {
+	if len(strings.TrimSpace(requestData.Name)) == 0 {
+		return fmt.Errorf("Name Is Required")
+	}
+
+	if len(requestData.Pattern) == 0 {
+		return fmt.Errorf("Pattern Is Required")
+	}
+
+	switch requestData.MatchType {
+	case models.AutoReplyMatchExact, models.AutoReplyMatchContains:
+	case models.AutoReplyMatchRegex:
+		if _, err := regexp.Compile(requestData.Pattern); err != nil {
+			return fmt.Errorf("Invalid Pattern")
+		}
+	default:
+		return fmt.Errorf("Invalid Match Type")
+	}
+
+	if (len(requestData.TimeFrom) == 0) != (len(requestData.TimeTo) == 0) {
+		return fmt.Errorf("Both Time From And Time To Are Required")
+	}
+	for _, clock := range []string{requestData.TimeFrom, requestData.TimeTo} {
+		if _, err := application.ParseClock(clock); len(clock) > 0 && err != nil {
+			return fmt.Errorf("Invalid Time Of Day")
+		}
+	}
+
+	if _, err := template.New("reply").Parse(requestData.ReplyText); err != nil {
+		return fmt.Errorf("Invalid Reply Template")
+	}
+
+	if requestData.CooldownSeconds < 0 {
+		return fmt.Errorf("Invalid Cooldown")
+	}
+
+	switch requestData.ReplyType {
+	case models.AutoReplyTypeText:
+		if len(requestData.ReplyText) == 0 {
+			return fmt.Errorf("Reply Text Is Required")
+		}
+	case models.AutoReplyTypeMedia:
+		if requestData.Media == nil && !hasMedia {
+			return fmt.Errorf("Media Is Required")
+		}
+		if requestData.Media != nil {
+			if data, err := base64.StdEncoding.DecodeString(requestData.Media.Data); err != nil || len(data) == 0 {
+				return fmt.Errorf("Invalid Media Data")
+			}
+		}
+	case models.AutoReplyTypeWebhook:
+		target, err := url.Parse(requestData.WebhookURL)
+		if err != nil || (target.Scheme != "http" && target.Scheme != "https") || len(target.Host) == 0 {
+			return fmt.Errorf("Invalid Webhook URL")
+		}
+	default:
+		return fmt.Errorf("Invalid Reply Type")
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"go.uber.org/zap"
+	"gomeow/pkg/application"
+	"io"
+	"net/http"
+)
+
+type markReadRequest struct {
+	MessageIds []string `json:"message_ids"`
+}
+
+type markReadResponse struct {
+	Chat         string   `json:"chat"`
+	Acknowledged []string `json:"acknowledged"`
+}
+
+// ChatRead sends read receipts for the listed inbound messages of the :chat phone number or JID,
+// or for the unacknowledged ones since the last acknowledged message when the body lists none.
+func ChatRead(app *application.Application) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		defer r.Body.Close()
+
+		session, ok := findSession(app, w, r)
+		if !ok {
+			return
+		}
+
+		chat, err := app.RecipientJID(p.ByName("chat"))
+		if err != nil {
+			writeErrorResponse(w, http.StatusUnprocessableEntity, "Invalid Chat")
+			return
+		}
+
+		var requestData markReadRequest
+		if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil && !errors.Is(err, io.EOF) {
+			writeErrorResponse(w, http.StatusUnprocessableEntity, "Unprocessable Entity")
+			return
+		}
+
+		if len(requestData.MessageIds) > application.MaxMarkRead {
+			writeFieldErrorResponse(w, "Invalid Parameter Supplied", []FieldError{
+				{"message_ids", fmt.Sprintf("must contain at most %d message ids", application.MaxMarkRead)},
+			})
+			return
+		}
+
+		acknowledged, err := app.MarkRead(session, chat, requestData.MessageIds)
+		if err != nil {
+			writeChatErrorResponse(w, err)
+			return
+		}
+
+		writeJsonResponse(w, http.StatusOK, "Messages marked as read", markReadResponse{chat.String(), acknowledged})
+	}
+}
+
+// ChatUpdate archives, pins, mutes or marks the :chat phone number or JID unread.
+func ChatUpdate(app *application.Application) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		defer r.Body.Close()
+
+		session, ok := findSession(app, w, r)
+		if !ok {
+			return
+		}
+
+		chat, err := app.RecipientJID(p.ByName("chat"))
+		if err != nil {
+			writeErrorResponse(w, http.StatusUnprocessableEntity, "Invalid Chat")
+			return
+		}
+
+		var requestData application.ChatState
+		if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+			writeErrorResponse(w, http.StatusUnprocessableEntity, "Unprocessable Entity")
+			return
+		}
+
+		var errs []FieldError
+		if requestData.Archived == nil && requestData.Pinned == nil && requestData.Muted == nil && requestData.Unread == nil {
+			errs = append(errs, FieldError{"body", "one of archived, pinned, muted or unread is required"})
+		}
+		if requestData.MuteDuration < 0 {
+			errs = append(errs, FieldError{"mute_duration", "must be a positive number of seconds, or 0 to mute forever"})
+		}
+		if len(errs) > 0 {
+			writeFieldErrorResponse(w, "Invalid Parameter Supplied", errs)
+			return
+		}
+
+		if err := app.UpdateChat(session, chat, requestData); err != nil {
+			writeChatErrorResponse(w, err)
+			return
+		}
+
+		writeJsonResponse(w, http.StatusOK, "Chat updated", requestData)
+	}
+}
+
+func writeChatErrorResponse(w http.ResponseWriter, err error) {
+	if errors.Is(err, application.ErrNotConnected) {
+		writeErrorResponse(w, http.StatusServiceUnavailable, "Session Is Not Connected")
+		return
+	}
+
+	zap.S().Errorf("Chat request failed: %s", err)
+	writeErrorResponse(w, http.StatusBadGateway, "WhatsApp Request Failed")
+}
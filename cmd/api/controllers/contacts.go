@@ -0,0 +1,167 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"go.mau.fi/whatsmeow"
+	"go.uber.org/zap"
+	"gomeow/pkg/application"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var pictureClient = &http.Client{Timeout: 30 * time.Second}
+
+type contactCheckRequest struct {
+	Phones []string `json:"phones"`
+}
+
+// ContactCheck tells for every number whether it is registered on WhatsApp, and its canonical JID.
+func ContactCheck(app *application.Application) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		defer r.Body.Close()
+
+		session, ok := findSession(app, w, r)
+		if !ok {
+			return
+		}
+
+		var requestData contactCheckRequest
+		if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+			writeErrorResponse(w, http.StatusUnprocessableEntity, "Unprocessable Entity")
+			return
+		}
+
+		if len(requestData.Phones) == 0 || len(requestData.Phones) > application.MaxContactChecks {
+			writeFieldErrorResponse(w, "Invalid Parameter Supplied", []FieldError{
+				{"phones", fmt.Sprintf("must contain 1 to %d numbers", application.MaxContactChecks)},
+			})
+			return
+		}
+
+		checks, err := app.CheckContacts(session, requestData.Phones)
+		if err != nil {
+			writeContactErrorResponse(w, err)
+			return
+		}
+
+		writeJsonResponse(w, http.StatusOK, "Contacts checked", checks)
+	}
+}
+
+// ContactIndex lists the contacts the session knows about.
+func ContactIndex(app *application.Application) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		session, ok := findSession(app, w, r)
+		if !ok {
+			return
+		}
+
+		contacts, err := app.Contacts(session)
+		if err != nil {
+			zap.S().Errorf(err.Error())
+			writeErrorResponse(w, http.StatusInternalServerError, "Internal Server Error")
+			return
+		}
+
+		writeJsonResponse(w, http.StatusOK, "Contacts found", contacts)
+	}
+}
+
+// ContactShow returns the profile of the :contact phone number or JID.
+func ContactShow(app *application.Application) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		session, ok := findSession(app, w, r)
+		if !ok {
+			return
+		}
+
+		jid, err := app.RecipientJID(p.ByName("contact"))
+		if err != nil {
+			writeErrorResponse(w, http.StatusUnprocessableEntity, "Invalid Phone Number")
+			return
+		}
+
+		profile, err := app.Profile(session, jid)
+		if err != nil {
+			writeContactErrorResponse(w, err)
+			return
+		}
+
+		writeJsonResponse(w, http.StatusOK, "Contact found", profile)
+	}
+}
+
+// ContactPicture serves the profile picture of the :contact phone number or JID, or with format=url,
+// where to download it. The preview parameter picks the thumbnail instead of the full size picture.
+func ContactPicture(app *application.Application) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		session, ok := findSession(app, w, r)
+		if !ok {
+			return
+		}
+
+		jid, err := app.RecipientJID(p.ByName("contact"))
+		if err != nil {
+			writeErrorResponse(w, http.StatusUnprocessableEntity, "Invalid Phone Number")
+			return
+		}
+
+		preview, _ := strconv.ParseBool(r.URL.Query().Get("preview"))
+		picture, err := app.ProfilePicture(session, jid, preview)
+		if err == nil && picture == nil {
+			err = whatsmeow.ErrProfilePictureNotSet
+		}
+		if err != nil {
+			writeContactErrorResponse(w, err)
+			return
+		}
+
+		if r.URL.Query().Get("format") == "url" {
+			writeJsonResponse(w, http.StatusOK, "Profile picture found", picture)
+			return
+		}
+
+		resp, err := pictureClient.Get(picture.URL)
+		if err != nil || resp.StatusCode != http.StatusOK {
+			if err == nil {
+				resp.Body.Close()
+				err = fmt.Errorf("status %s", resp.Status)
+			}
+			zap.S().Errorf("Failed to download the profile picture of %s: %s", jid, err)
+			writeErrorResponse(w, http.StatusBadGateway, "Profile Picture Download Failed")
+			return
+		}
+		defer resp.Body.Close()
+
+		w.Header().Set("Content-Type", resp.Header.Get("Content-Type"))
+		w.Header().Set("Cache-Control", "private, max-age=3600")
+		if _, err := io.Copy(w, resp.Body); err != nil {
+			zap.S().Errorf(err.Error())
+		}
+	}
+}
+
+func writeContactErrorResponse(w http.ResponseWriter, err error) {
+	if errors.Is(err, application.ErrNotConnected) {
+		writeErrorResponse(w, http.StatusServiceUnavailable, "Session Is Not Connected")
+		return
+	}
+
+	if errors.Is(err, whatsmeow.ErrProfilePictureNotSet) {
+		writeErrorResponse(w, http.StatusNotFound, "No Profile Picture")
+		return
+	}
+
+	if errors.Is(err, whatsmeow.ErrProfilePictureUnauthorized) {
+		writeErrorResponse(w, http.StatusForbidden, "Profile Picture Is Hidden")
+		return
+	}
+
+	zap.S().Errorf("Contact lookup failed: %s", err)
+	writeErrorResponse(w, http.StatusBadGateway, "WhatsApp Request Failed")
+}
@@ -0,0 +1,168 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/websocket"
+	"github.com/julienschmidt/httprouter"
+	"go.uber.org/zap"
+	"gomeow/cmd/models"
+	"gomeow/pkg/application"
+	"gomeow/pkg/middleware"
+	"gomeow/pkg/stream"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var upgrader = websocket.Upgrader{
+	// the stream is protected by the API token instead of the origin
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// EventStream pushes gateway events over WebSocket when the request asks for an upgrade,
+// or as Server-Sent Events otherwise.
+func EventStream(app *application.Application) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		filter := stream.Filter{
+			Sessions: splitQuery(r.URL.Query().Get("sessions")),
+			Types:    splitQuery(r.URL.Query().Get("types")),
+			Chats:    splitQuery(r.URL.Query().Get("chats")),
+		}
+
+		if key := middleware.ApiKey(r); key == nil || !key.HasScope(models.ApiScopeAdmin) {
+			filter.ExcludeTypes = application.PairingStreamEvents
+		}
+
+		lastEventId := r.Header.Get("Last-Event-ID")
+		if len(lastEventId) == 0 {
+			lastEventId = r.URL.Query().Get("last_event_id")
+		}
+		resumeFrom, _ := strconv.ParseUint(lastEventId, 10, 64)
+
+		if websocket.IsWebSocketUpgrade(r) {
+			streamWebSocket(app.Stream, w, r, filter, resumeFrom)
+			return
+		}
+
+		streamServerSentEvents(app.Stream, w, r, filter, resumeFrom)
+	}
+}
+
+func streamServerSentEvents(hub *stream.Hub, w http.ResponseWriter, r *http.Request, filter stream.Filter, resumeFrom uint64) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeErrorResponse(w, http.StatusInternalServerError, "Streaming Not Supported")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	subscriber, missed := hub.Subscribe(filter, resumeFrom)
+	defer hub.Unsubscribe(subscriber)
+
+	write := func(event stream.Event) error {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+
+		_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.Id, event.Type, data)
+		flusher.Flush()
+		return err
+	}
+
+	for _, event := range missed {
+		if err := write(event); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(15 * time.Second)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case event, open := <-subscriber.Events:
+			if !open {
+				return
+			}
+			if err := write(event); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func streamWebSocket(hub *stream.Hub, w http.ResponseWriter, r *http.Request, filter stream.Filter, resumeFrom uint64) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		zap.S().Warnf("Failed to upgrade event stream: %s", err)
+		return
+	}
+	defer conn.Close()
+
+	subscriber, missed := hub.Subscribe(filter, resumeFrom)
+	defer hub.Unsubscribe(subscriber)
+
+	// the client isn't expected to send anything, read only to notice when it goes away
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for _, event := range missed {
+		if err := conn.WriteJSON(event); err != nil {
+			return
+		}
+	}
+
+	ping := time.NewTicker(30 * time.Second)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(10*time.Second)); err != nil {
+				return
+			}
+		case event, open := <-subscriber.Events:
+			if !open {
+				return
+			}
+			if err := conn.WriteJSON(event); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func splitQuery(value string) []string {
+	var values []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); len(v) > 0 {
+			values = append(values, v)
+		}
+	}
+
+	return values
+}
@@ -0,0 +1,49 @@
+package controllers
+
+import (
+	"errors"
+	"github.com/julienschmidt/httprouter"
+	"go.uber.org/zap"
+	"gomeow/cmd/models"
+	"gomeow/pkg/application"
+	"gomeow/pkg/storage"
+	"io"
+	"mime"
+	"net/http"
+)
+
+func MessageMedia(app *application.Application) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		message := models.Message{}
+		if app.MessageStore.Where("message_id = ?", p.ByName("id")).First(&message).RecordNotFound() {
+			writeErrorResponse(w, http.StatusNotFound, "Message Not Found")
+			return
+		}
+
+		if len(message.StorageKey) == 0 {
+			writeErrorResponse(w, http.StatusNotFound, "Media Not Found")
+			return
+		}
+
+		file, err := app.Storage.Get(message.StorageKey)
+		if errors.Is(err, storage.ErrNotFound) {
+			writeErrorResponse(w, http.StatusNotFound, "Media Not Found")
+			return
+		}
+		if err != nil {
+			zap.S().Errorf("Failed to read media of message %s: %s", message.MessageId, err)
+			writeErrorResponse(w, http.StatusInternalServerError, "Internal Server Error")
+			return
+		}
+		defer file.Close()
+
+		w.Header().Set("Content-Type", message.MimeType)
+		if len(message.FileName) > 0 {
+			w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": message.FileName}))
+		}
+
+		if _, err := io.Copy(w, file); err != nil {
+			zap.S().Errorf("Failed to send media of message %s: %s", message.MessageId, err)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/jinzhu/gorm"
+	"github.com/julienschmidt/httprouter"
+	"gomeow/cmd/models"
+	"gomeow/pkg/application"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultMessageLimit = 50
+	maxMessageLimit     = 200
+)
+
+// messageSorts are the columns messages can be sorted by. They are never null,
+// so together with the id they give a stable order to page through.
+var messageSorts = map[string]bool{
+	"id":         false,
+	"created_at": true,
+	"updated_at": true,
+}
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+type messagePage struct {
+	Messages   []models.Message `json:"messages"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+	HasMore    bool             `json:"has_more"`
+}
+
+// messageCursor points behind the last message of a page.
+type messageCursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v,omitempty"`
+	ID    int64  `json:"id"`
+}
+
+// MessageList pages through the message store, newest first unless sorted otherwise.
+//
+// Filters: destination, jid (of the sending device), sender, status, direction, category,
+// from and to (RFC 3339 or 2006-01-02, on created_at), acknowledged (whether a read receipt
+// has been sent) and q (text in the body).
+// Paging: sort (id, created_at or updated_at, prefixed with - for descending order), limit and cursor.
+func MessageList(app *application.Application) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		query := r.URL.Query()
+
+		sort := query.Get("sort")
+		if len(sort) == 0 {
+			sort = "-id"
+		}
+		column := strings.TrimPrefix(sort, "-")
+		descending := column != sort
+		isTime, ok := messageSorts[column]
+		if !ok {
+			writeErrorResponse(w, http.StatusUnprocessableEntity, "Invalid Sort")
+			return
+		}
+
+		limit := defaultMessageLimit
+		if len(query.Get("limit")) > 0 {
+			var err error
+			if limit, err = strconv.Atoi(query.Get("limit")); err != nil || limit < 1 || limit > maxMessageLimit {
+				writeErrorResponse(w, http.StatusUnprocessableEntity, "Invalid Limit")
+				return
+			}
+		}
+
+		db, err := filterMessages(app.MessageStore, query, app.Cfg.GetLocation())
+		if err != nil {
+			writeErrorResponse(w, http.StatusUnprocessableEntity, "Invalid Date Range")
+			return
+		}
+
+		direction, comparison := "asc", ">"
+		if descending {
+			direction, comparison = "desc", "<"
+		}
+
+		if len(query.Get("cursor")) > 0 {
+			cursor, err := decodeMessageCursor(query.Get("cursor"), sort)
+			if err != nil {
+				writeErrorResponse(w, http.StatusUnprocessableEntity, "Invalid Cursor")
+				return
+			}
+
+			if column == "id" {
+				db = db.Where("id "+comparison+" ?", cursor.ID)
+			} else {
+				value, err := time.Parse(time.RFC3339Nano, cursor.Value)
+				if err != nil {
+					writeErrorResponse(w, http.StatusUnprocessableEntity, "Invalid Cursor")
+					return
+				}
+				db = db.Where(column+" "+comparison+" ? OR ("+column+" = ? AND id "+comparison+" ?)", value, value, cursor.ID)
+			}
+		}
+
+		if column != "id" {
+			db = db.Order(column + " " + direction)
+		}
+
+		page := messagePage{Messages: []models.Message{}}
+		db.Order("id " + direction).Limit(limit + 1).Find(&page.Messages)
+
+		if len(page.Messages) > limit {
+			page.Messages = page.Messages[:limit]
+			page.HasMore = true
+
+			last := page.Messages[limit-1]
+			cursor := messageCursor{Sort: sort, ID: last.ID}
+			if isTime {
+				value := last.CreatedAt
+				if column == "updated_at" {
+					value = last.UpdatedAt
+				}
+				cursor.Value = value.Format(time.RFC3339Nano)
+			}
+			page.NextCursor = encodeMessageCursor(cursor)
+		}
+
+		writeJsonResponse(w, http.StatusOK, "Messages found", page)
+	}
+}
+
+func filterMessages(db *gorm.DB, query url.Values, location *time.Location) (*gorm.DB, error) {
+	for param, column := range map[string]string{
+		"destination": "destination",
+		"jid":         "jid",
+		"sender":      "sender",
+		"status":      "status",
+		"direction":   "direction",
+		"category":    "category",
+	} {
+		if value := query.Get(param); len(value) > 0 {
+			db = db.Where(column+" = ?", value)
+		}
+	}
+
+	if from := query.Get("from"); len(from) > 0 {
+		at, err := parseMessageDate(from, false, location)
+		if err != nil {
+			return nil, err
+		}
+		db = db.Where("created_at >= ?", at)
+	}
+
+	if to := query.Get("to"); len(to) > 0 {
+		at, err := parseMessageDate(to, true, location)
+		if err != nil {
+			return nil, err
+		}
+		db = db.Where("created_at < ?", at)
+	}
+
+	if acknowledged, err := strconv.ParseBool(query.Get("acknowledged")); err == nil {
+		if acknowledged {
+			db = db.Where("receipt_sent_at IS NOT NULL")
+		} else {
+			db = db.Where("receipt_sent_at IS NULL")
+		}
+	}
+
+	if text := query.Get("q"); len(text) > 0 {
+		escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(text)
+		db = db.Where("body LIKE ?", "%"+escaped+"%")
+	}
+
+	return db, nil
+}
+
+// parseMessageDate parses a timestamp or a date in the location. Dates ending a range include the whole day.
+func parseMessageDate(value string, end bool, location *time.Location) (time.Time, error) {
+	if at, err := time.Parse(time.RFC3339, value); err == nil {
+		return at, nil
+	}
+
+	day, err := time.ParseInLocation("2006-01-02", value, location)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if end {
+		day = day.AddDate(0, 0, 1)
+	}
+
+	return day, nil
+}
+
+func encodeMessageCursor(cursor messageCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeMessageCursor decodes the cursor, which has to be for the same sort order.
+func decodeMessageCursor(value string, sort string) (messageCursor, error) {
+	cursor := messageCursor{}
+
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return cursor, errInvalidCursor
+	}
+
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.Sort != sort {
+		return cursor, errInvalidCursor
+	}
+
+	return cursor, nil
+}
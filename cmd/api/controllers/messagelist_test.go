@@ -0,0 +1,133 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"gomeow/cmd/models"
+	"gomeow/pkg/application"
+	"gomeow/pkg/config"
+	"gomeow/pkg/testdb"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newTestMessageList stores five messages, whose created_at and updated_at tie and don't follow their ids.
+func newTestMessageList(t *testing.T) *application.Application {
+	db := testdb.Open(t, &models.Message{})
+	t0 := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	t1, t2 := t0.Add(time.Minute), t0.Add(time.Hour)
+
+	for i, at := range []struct{ created, updated time.Time }{
+		{t1, t2},
+		{t0, t2},
+		{t2, t0},
+		{t1, t1},
+		{t1, t0},
+	} {
+		message := models.Message{
+			JID:         "628111@s.whatsapp.net",
+			MessageId:   fmt.Sprintf("message-%d", i+1),
+			Destination: "628222",
+			CreatedAt:   at.created,
+			UpdatedAt:   at.updated,
+		}
+		if err := db.Create(&message).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return &application.Application{MessageStore: db, Cfg: &config.Config{}}
+}
+
+func listMessages(app *application.Application, query url.Values) (int, messagePage) {
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/messages?"+query.Encode(), nil)
+	w := httptest.NewRecorder()
+	MessageList(app)(w, r, nil)
+
+	page := messagePage{}
+	_ = json.NewDecoder(w.Body).Decode(&jsonResponse{Data: &page})
+
+	return w.Code, page
+}
+
+func TestMessageListPages(t *testing.T) {
+	app := newTestMessageList(t)
+
+	tests := []struct {
+		sort  string
+		limit string
+		pages [][]int64
+	}{
+		{"", "2", [][]int64{{5, 4}, {3, 2}, {1}}},
+		{"id", "2", [][]int64{{1, 2}, {3, 4}, {5}}},
+		{"-id", "5", [][]int64{{5, 4, 3, 2, 1}}},
+		{"-id", "4", [][]int64{{5, 4, 3, 2}, {1}}},
+		{"created_at", "2", [][]int64{{2, 1}, {4, 5}, {3}}},
+		{"-created_at", "2", [][]int64{{3, 5}, {4, 1}, {2}}},
+		{"created_at", "1", [][]int64{{2}, {1}, {4}, {5}, {3}}},
+		{"updated_at", "3", [][]int64{{3, 5, 4}, {1, 2}}},
+		{"-updated_at", "3", [][]int64{{2, 1, 4}, {5, 3}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.sort+" by "+test.limit, func(t *testing.T) {
+			query := url.Values{"limit": {test.limit}}
+			if len(test.sort) > 0 {
+				query.Set("sort", test.sort)
+			}
+
+			for i, expected := range test.pages {
+				code, page := listMessages(app, query)
+				if code != http.StatusOK {
+					t.Fatalf("page %d: expected status 200, got %d", i, code)
+				}
+
+				var ids []int64
+				for _, message := range page.Messages {
+					ids = append(ids, message.ID)
+				}
+				if !reflect.DeepEqual(ids, expected) {
+					t.Fatalf("page %d: expected %v, got %v", i, expected, ids)
+				}
+
+				last := i == len(test.pages)-1
+				if page.HasMore == last || (len(page.NextCursor) > 0) == last {
+					t.Fatalf("page %d: expected has_more to be %t, got %t with cursor %q", i, !last, page.HasMore, page.NextCursor)
+				}
+
+				query.Set("cursor", page.NextCursor)
+			}
+		})
+	}
+}
+
+func TestMessageListInvalidCursor(t *testing.T) {
+	app := newTestMessageList(t)
+
+	_, page := listMessages(app, url.Values{"sort": {"-id"}, "limit": {"2"}})
+	if len(page.NextCursor) == 0 {
+		t.Fatal("expected a cursor")
+	}
+
+	tests := []struct {
+		name   string
+		sort   string
+		cursor string
+	}{
+		{"from another sort", "id", page.NextCursor},
+		{"from another column", "-created_at", page.NextCursor},
+		{"not base64", "-id", "not a cursor!"},
+		{"not json", "-id", "bm90IGpzb24"},
+	}
+
+	for _, test := range tests {
+		code, _ := listMessages(app, url.Values{"sort": {test.sort}, "cursor": {test.cursor}})
+		if code != http.StatusUnprocessableEntity {
+			t.Errorf("%s: expected status 422, got %d", test.name, code)
+		}
+	}
+}
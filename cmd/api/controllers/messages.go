@@ -2,15 +2,18 @@ package controllers
 
 import (
 	"encoding/json"
+	"errors"
 	"github.com/julienschmidt/httprouter"
-	"go.mau.fi/whatsmeow"
 	"go.uber.org/zap"
 	"gomeow/cmd/models"
+	"gomeow/pkg/apikey"
 	"gomeow/pkg/application"
+	"gomeow/pkg/middleware"
+	"gomeow/pkg/phone"
 	"io"
 	"log"
 	"net/http"
-	"time"
+	"strconv"
 )
 
 type JsonErrorResponse struct {
@@ -18,8 +21,15 @@ type JsonErrorResponse struct {
 }
 
 type ApiError struct {
-	Status int    `json:"status"`
-	Title  string `json:"title"`
+	Status int          `json:"status"`
+	Title  string       `json:"title"`
+	Errors []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError tells which field of the request is invalid, and why.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
 }
 
 type returnData struct {
@@ -28,8 +38,42 @@ type returnData struct {
 	Data    application.PendingMessage `json:"data"`
 }
 
-func MessageIndex(app *application.Application) httprouter.Handle {
+type jsonResponse struct {
+	Status  bool        `json:"status"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data"`
+}
+
+type messageDetail struct {
+	models.Message
+	Recipients []models.MessageRecipient `json:"recipients"`
+	Timeline   []models.MessageEvent     `json:"timeline"`
+}
+
+// MessageIndex lists messages, unless the request carries a message, which is queued like it always has been.
+func MessageIndex(list httprouter.Handle, queue httprouter.Handle) httprouter.Handle {
 	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		if _, ok := r.URL.Query()["message"]; ok {
+			queue(w, r, p)
+			return
+		}
+
+		list(w, r, p)
+	}
+}
+
+// MessageQueue queues the message of the query parameters. It is deprecated in favour of MessageStore,
+// as the message ends up in access logs, and can be turned off with LEGACY_GET_SEND.
+func MessageQueue(app *application.Application) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		if !app.Cfg.GetLegacyGetSend() {
+			writeErrorResponse(w, http.StatusGone, "Sending With GET Is Disabled, Use POST /api/v1/messages")
+			return
+		}
+
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", `</api/v1/messages>; rel="successor-version"`)
+
 		defer func(Body io.ReadCloser) {
 			err := Body.Close()
 			if err != nil {
@@ -37,35 +81,30 @@ func MessageIndex(app *application.Application) httprouter.Handle {
 			}
 		}(r.Body)
 
-		to := r.URL.Query().Get("destination")
-		message := r.URL.Query().Get("message")
-		newMessageId := whatsmeow.GenerateMessageID()
+		query := r.URL.Query()
+		to := query.Get("destination")
+		message := query.Get("message")
 
 		if (len(to) == 0) || (len(message) == 0) {
 			writeErrorResponse(w, 422, "Invalid Parameter Supplied")
 			return
 		}
 
-		zap.S().Debugf("Queueing message with ID: %s and content: %s to %s", newMessageId, message, to)
-
-		pendingMessage := application.PendingMessage{
-			To:        to,
-			MessageId: newMessageId,
-			Message:   message,
+		urgent, _ := strconv.ParseBool(query.Get("urgent"))
+		pendingMessage, ok := queueMessage(app, w, r, application.PendingMessage{
+			Session:     requestedSession(r),
+			To:          to,
+			Message:     message,
+			Category:    query.Get("category"),
+			WindowStart: query.Get("window_start"),
+			WindowEnd:   query.Get("window_end"),
+			Timezone:    query.Get("timezone"),
+			Urgent:      urgent,
+		})
+		if !ok {
+			return
 		}
 
-		// store to message store
-		storeToMessageStore(
-			app,
-			app.Meow.DeviceStore.ID.String(),
-			to,
-			message,
-			newMessageId,
-		)
-
-		// add to queue
-		app.Queue.Add(pendingMessage)
-
 		formattedValues := returnData{
 			Status:  true,
 			Message: "Message queued",
@@ -81,6 +120,34 @@ func MessageIndex(app *application.Application) httprouter.Handle {
 	}
 }
 
+func MessageShow(app *application.Application) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+		detail := messageDetail{
+			Recipients: []models.MessageRecipient{},
+			Timeline:   []models.MessageEvent{},
+		}
+
+		if app.MessageStore.Where("message_id = ?", p.ByName("id")).First(&detail.Message).RecordNotFound() {
+			writeErrorResponse(w, http.StatusNotFound, "Message Not Found")
+			return
+		}
+
+		app.MessageStore.
+			Where("message_id = ?", detail.MessageId).
+			Order("participant").
+			Find(&detail.Recipients)
+
+		app.MessageStore.
+			Where("message_id = ?", detail.MessageId).
+			Order("occurred_at, id").
+			Find(&detail.Timeline)
+
+		writeJsonResponse(w, http.StatusOK, "Message found", detail)
+	}
+}
+
 // Writes the error response as a Standard API JSON response with a response code
 func writeErrorResponse(w http.ResponseWriter, errorCode int, errorMsg string) {
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
@@ -93,15 +160,104 @@ func writeErrorResponse(w http.ResponseWriter, errorCode int, errorMsg string) {
 	}
 }
 
-func storeToMessageStore(app *application.Application, jid string, destination string, body string, messageId string) {
-	storedMessage := models.Message{
-		JID:         jid,
-		Destination: destination,
-		MessageId:   messageId,
-		Body:        body,
-		CreatedAt:   time.Time{},
-		UpdatedAt:   time.Time{},
+// Queues the message, counting it against the quotas of the API key of the request.
+// Writes the error response when it couldn't be queued.
+func queueMessage(app *application.Application, w http.ResponseWriter, r *http.Request, pm application.PendingMessage) (application.PendingMessage, bool) {
+	key := middleware.ApiKey(r)
+	if key != nil {
+		retryAfter, err := app.Keys.Reserve(key, 1)
+		if errors.Is(err, apikey.ErrQuotaExceeded) {
+			middleware.TooManyRequests(w, "Message Quota Exceeded", retryAfter)
+			return pm, false
+		}
+		if err != nil {
+			zap.S().Errorf(err.Error())
+			writeErrorResponse(w, http.StatusInternalServerError, "Internal Server Error")
+			return pm, false
+		}
+	}
+
+	pendingMessage, err := app.QueueMessage(pm)
+	if err != nil {
+		if key != nil {
+			if err := app.Keys.Release(key, 1); err != nil {
+				zap.S().Errorf("Failed to release the quota of api key %d: %s", key.ID, err)
+			}
+		}
+
+		writeQueueErrorResponse(w, err)
+		return pm, false
+	}
+
+	return pendingMessage, true
+}
+
+// Writes the errors of invalid request fields as a Standard API JSON response
+func writeFieldErrorResponse(w http.ResponseWriter, errorMsg string, errs []FieldError) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	err := json.
+		NewEncoder(w).Encode(&JsonErrorResponse{Error: &ApiError{Status: http.StatusUnprocessableEntity, Title: errorMsg, Errors: errs}})
+
+	if err != nil {
+		zap.S().Errorf(err.Error())
+	}
+}
+
+// Writes the reason a message couldn't be queued
+func writeQueueErrorResponse(w http.ResponseWriter, err error) {
+	if errors.Is(err, application.ErrRecipientSuppressed) {
+		writeErrorResponse(w, http.StatusUnprocessableEntity, "Recipient Has Opted Out")
+		return
+	}
+
+	if errors.Is(err, phone.ErrInvalidPhone) {
+		writeFieldErrorResponse(w, "Invalid Phone Number", []FieldError{{"to", "is not a valid phone number"}})
+		return
 	}
 
-	app.MessageStore.Create(&storedMessage)
+	if errors.Is(err, application.ErrNotOnWhatsApp) {
+		writeFieldErrorResponse(w, "Recipient Is Not On WhatsApp", []FieldError{{"to", "is not registered on WhatsApp"}})
+		return
+	}
+
+	if errors.Is(err, application.ErrSessionNotFound) {
+		writeErrorResponse(w, http.StatusNotFound, "Session Not Found")
+		return
+	}
+
+	if errors.Is(err, application.ErrNotPaired) {
+		writeErrorResponse(w, http.StatusServiceUnavailable, "Device Is Not Paired")
+		return
+	}
+
+	if errors.Is(err, application.ErrInvalidDeliveryWindow) {
+		writeFieldErrorResponse(w, "Invalid Delivery Window", []FieldError{{"window_start", "window_start and window_end must both be HH:MM"}})
+		return
+	}
+
+	if errors.Is(err, application.ErrEmptyDeliveryWindow) {
+		writeFieldErrorResponse(w, "Invalid Delivery Window", []FieldError{{"window_end", "must differ from window_start"}})
+		return
+	}
+
+	if errors.Is(err, application.ErrInvalidTimezone) {
+		writeFieldErrorResponse(w, "Invalid Timezone", []FieldError{{"timezone", "unknown IANA timezone"}})
+		return
+	}
+
+	zap.S().Errorf(err.Error())
+	writeErrorResponse(w, http.StatusInternalServerError, "Internal Server Error")
+}
+
+// Writes the data as a Standard API JSON response with a response code
+func writeJsonResponse(w http.ResponseWriter, statusCode int, message string, data interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(statusCode)
+	err := json.
+		NewEncoder(w).Encode(&jsonResponse{Status: true, Message: message, Data: data})
+
+	if err != nil {
+		zap.S().Errorf(err.Error())
+	}
 }
@@ -0,0 +1,157 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"go.uber.org/zap"
+	"gomeow/pkg/application"
+	"math"
+	"net/http"
+	"rsc.io/qr"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type pairPhoneRequest struct {
+	Phone string `json:"phone"`
+}
+
+func PairingShow(app *application.Application) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		session, ok := findSession(app, w, r)
+		if !ok {
+			return
+		}
+
+		writeJsonResponse(w, http.StatusOK, "Pairing status", session.Meow().Pairing())
+	}
+}
+
+// PairingStart starts a new pairing, e.g. after the previous one timed out.
+func PairingStart(app *application.Application) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		session, ok := findSession(app, w, r)
+		if !ok {
+			return
+		}
+
+		if err := session.Meow().StartPairing(); err != nil {
+			writePairingErrorResponse(w, err)
+			return
+		}
+
+		writeJsonResponse(w, http.StatusAccepted, "Pairing started", session.Meow().Pairing())
+	}
+}
+
+// PairingQR renders the current QR code as png (default), svg or text.
+// The Refresh header tells browsers to reload once the code rotates.
+func PairingQR(app *application.Application) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		session, ok := findSession(app, w, r)
+		if !ok {
+			return
+		}
+
+		state := session.Meow().Pairing()
+		if len(state.Code) == 0 {
+			writeErrorResponse(w, http.StatusNotFound, "No QR Code Available")
+			return
+		}
+
+		code, err := qr.Encode(state.Code, qr.L)
+		if err != nil {
+			zap.S().Errorf("Failed to encode QR code: %s", err)
+			writeErrorResponse(w, http.StatusInternalServerError, "Internal Server Error")
+			return
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("X-Pairing-Status", state.Status)
+		if state.ExpiresAt != nil {
+			refresh := math.Ceil(time.Until(*state.ExpiresAt).Seconds())
+			w.Header().Set("Refresh", strconv.Itoa(int(math.Max(refresh, 1))))
+		}
+
+		var body []byte
+		switch r.URL.Query().Get("format") {
+		case "svg":
+			w.Header().Set("Content-Type", "image/svg+xml")
+			body = []byte(qrSVG(code))
+		case "text":
+			w.Header().Set("Content-Type", "text/plain; charset=UTF-8")
+			body = []byte(state.Code)
+		case "", "png":
+			w.Header().Set("Content-Type", "image/png")
+			body = code.PNG()
+		default:
+			writeErrorResponse(w, http.StatusUnprocessableEntity, "Invalid Format")
+			return
+		}
+
+		if _, err := w.Write(body); err != nil {
+			zap.S().Errorf(err.Error())
+		}
+	}
+}
+
+// PairingPhone links the device with a code entered on the phone instead of scanning a QR code.
+func PairingPhone(app *application.Application) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		defer r.Body.Close()
+
+		session, ok := findSession(app, w, r)
+		if !ok {
+			return
+		}
+
+		var requestData pairPhoneRequest
+		if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+			writeErrorResponse(w, http.StatusUnprocessableEntity, "Unprocessable Entity")
+			return
+		}
+
+		phone, err := app.Phone.Normalize(requestData.Phone)
+		if err != nil {
+			writeFieldErrorResponse(w, "Invalid Phone Number", []FieldError{{"phone", "is not a valid phone number"}})
+			return
+		}
+
+		if _, err := session.Meow().PairPhone(phone); err != nil {
+			writePairingErrorResponse(w, err)
+			return
+		}
+
+		writeJsonResponse(w, http.StatusAccepted, "Enter the pairing code on the phone", session.Meow().Pairing())
+	}
+}
+
+func writePairingErrorResponse(w http.ResponseWriter, err error) {
+	if errors.Is(err, application.ErrAlreadyPaired) {
+		writeErrorResponse(w, http.StatusConflict, "Already Paired")
+		return
+	}
+
+	zap.S().Errorf("Pairing failed: %s", err)
+	writeErrorResponse(w, http.StatusBadGateway, "Pairing Failed")
+}
+
+// qrSVG draws every black module of the code as a unit square.
+func qrSVG(code *qr.Code) string {
+	var svg strings.Builder
+	svg.WriteString(fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" shape-rendering="crispEdges">`, code.Size, code.Size))
+	svg.WriteString(`<rect width="100%" height="100%" fill="#fff"/><path fill="#000" d="`)
+	for y := 0; y < code.Size; y++ {
+		for x := 0; x < code.Size; x++ {
+			if code.Black(x, y) {
+				svg.WriteString(fmt.Sprintf("M%d %dh1v1h-1z", x, y))
+			}
+		}
+	}
+	svg.WriteString(`"/></svg>`)
+
+	return svg.String()
+}
@@ -0,0 +1,66 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/julienschmidt/httprouter"
+	"go.mau.fi/whatsmeow"
+	"go.uber.org/zap"
+	"gomeow/pkg/application"
+	"net/http"
+)
+
+type presenceRequest struct {
+	To    string `json:"to"`
+	State string `json:"state"`
+}
+
+// PresenceStore sets the presence of the session. With a recipient, the state is composing,
+// recording or paused in that chat, without one it is available or unavailable to all contacts.
+func PresenceStore(app *application.Application) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		defer r.Body.Close()
+
+		session, ok := findSession(app, w, r)
+		if !ok {
+			return
+		}
+
+		var requestData presenceRequest
+		if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+			writeErrorResponse(w, http.StatusUnprocessableEntity, "Unprocessable Entity")
+			return
+		}
+
+		if !session.Meow().Online() {
+			writeErrorResponse(w, http.StatusServiceUnavailable, "Session Is Not Connected")
+			return
+		}
+
+		var err error
+		if len(requestData.To) > 0 {
+			jid, jidErr := app.RecipientJID(requestData.To)
+			if jidErr != nil {
+				writeFieldErrorResponse(w, "Invalid Phone Number", []FieldError{{"to", "is not a valid phone number"}})
+				return
+			}
+			err = session.Meow().SetChatPresence(jid, requestData.State)
+		} else {
+			err = session.Meow().SetPresence(requestData.State)
+		}
+
+		switch {
+		case errors.Is(err, application.ErrInvalidPresence) && len(requestData.To) > 0:
+			writeFieldErrorResponse(w, "Invalid Presence", []FieldError{{"state", "must be composing, recording or paused"}})
+		case errors.Is(err, application.ErrInvalidPresence):
+			writeFieldErrorResponse(w, "Invalid Presence", []FieldError{{"state", "must be available or unavailable"}})
+		case errors.Is(err, whatsmeow.ErrNoPushName):
+			writeErrorResponse(w, http.StatusConflict, "Session Has No Push Name Yet")
+		case err != nil:
+			zap.S().Errorf("Failed to set presence: %s", err)
+			writeErrorResponse(w, http.StatusBadGateway, "WhatsApp Request Failed")
+		default:
+			writeJsonResponse(w, http.StatusOK, "Presence set", requestData)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"go.mau.fi/whatsmeow/types"
+	"go.uber.org/zap"
+	"gomeow/cmd/models"
+	"gomeow/pkg/application"
+	"gomeow/pkg/phone"
+	"gomeow/pkg/webhook"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxTextLength is the longest text WhatsApp accepts in a message.
+const maxTextLength = 65536
+
+// mediaSizeLimits are the largest files WhatsApp accepts per message type.
+var mediaSizeLimits = map[string]int{
+	models.MessageTypeImage:    16 << 20,
+	models.MessageTypeVideo:    16 << 20,
+	models.MessageTypeAudio:    16 << 20,
+	models.MessageTypeDocument: 100 << 20,
+}
+
+// maxSendRequestSize fits the largest document, base64 encoded.
+const maxSendRequestSize = 140 << 20
+
+type sendMessageMedia struct {
+	Data     string `json:"data"`
+	MimeType string `json:"mime_type"`
+	FileName string `json:"file_name"`
+}
+
+type sendMessageRequest struct {
+	Session     string            `json:"session"`
+	To          string            `json:"to"`
+	Type        string            `json:"type"`
+	Text        string            `json:"text"`
+	Media       *sendMessageMedia `json:"media"`
+	Category    string            `json:"category"`
+	WindowStart string            `json:"window_start"`
+	WindowEnd   string            `json:"window_end"`
+	Timezone    string            `json:"timezone"`
+	Urgent      bool              `json:"urgent"`
+}
+
+// MessageStore queues a text or media message. The text is the caption of media messages,
+// media is base64 encoded. The session is taken from the body, the X-Session header or the query.
+func MessageStore(app *application.Application) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		defer r.Body.Close()
+
+		var requestData sendMessageRequest
+		decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSendRequestSize))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&requestData); err != nil {
+			var maxBytesError *http.MaxBytesError
+			if errors.As(err, &maxBytesError) {
+				writeErrorResponse(w, http.StatusRequestEntityTooLarge, "Request Entity Too Large")
+				return
+			}
+
+			writeFieldErrorResponse(w, "Unprocessable Entity", []FieldError{{"body", err.Error()}})
+			return
+		}
+
+		data, errs := validateSendMessageRequest(&requestData, app.Phone)
+		if len(errs) > 0 {
+			writeFieldErrorResponse(w, "Invalid Parameter Supplied", errs)
+			return
+		}
+
+		pm := application.PendingMessage{
+			Session:     requestData.Session,
+			To:          requestData.To,
+			Type:        requestData.Type,
+			Message:     requestData.Text,
+			Category:    requestData.Category,
+			WindowStart: requestData.WindowStart,
+			WindowEnd:   requestData.WindowEnd,
+			Timezone:    requestData.Timezone,
+			Urgent:      requestData.Urgent,
+		}
+		if len(pm.Session) == 0 {
+			pm.Session = requestedSession(r)
+		}
+
+		if requestData.Media != nil {
+			extension := filepath.Ext(requestData.Media.FileName)
+			if len(extension) == 0 {
+				if extensions, _ := mime.ExtensionsByType(requestData.Media.MimeType); len(extensions) > 0 {
+					extension = extensions[0]
+				}
+			}
+
+			pm.MediaKey = fmt.Sprintf("outbound/%s%s", webhook.GenerateSecret(16), extension)
+			pm.MimeType = requestData.Media.MimeType
+			pm.FileName = requestData.Media.FileName
+
+			if err := app.Storage.Put(pm.MediaKey, pm.MimeType, data); err != nil {
+				zap.S().Errorf("Failed to store outbound media: %s", err)
+				writeErrorResponse(w, http.StatusInternalServerError, "Internal Server Error")
+				return
+			}
+		}
+
+		pendingMessage, ok := queueMessage(app, w, r, pm)
+		if !ok {
+			if len(pm.MediaKey) > 0 {
+				if err := app.Storage.Delete(pm.MediaKey); err != nil {
+					zap.S().Errorf("Failed to delete media of unqueued message: %s", err)
+				}
+			}
+			return
+		}
+
+		writeJsonResponse(w, http.StatusAccepted, "Message queued", pendingMessage)
+	}
+}
+
+// validateSendMessageRequest checks every field, returning the decoded media and the errors of all invalid fields.
+// It normalizes the phone number, and fills in the type and MIME type when they can be derived from the media.
+func validateSendMessageRequest(requestData *sendMessageRequest, normalizer *phone.Normalizer) ([]byte, []FieldError) {
+	var errs []FieldError
+
+	requestData.To = strings.TrimSpace(requestData.To)
+	switch {
+	case len(requestData.To) == 0:
+		errs = append(errs, FieldError{"to", "is required"})
+	case strings.Contains(requestData.To, "@"):
+		if _, err := types.ParseJID(requestData.To); err != nil {
+			errs = append(errs, FieldError{"to", "is not a valid JID"})
+		}
+	default:
+		number, err := normalizer.Normalize(requestData.To)
+		if err != nil {
+			errs = append(errs, FieldError{"to", "must be a phone number in international format, or national with DEFAULT_COUNTRY_CODE set"})
+		}
+		requestData.To = number
+	}
+
+	var data []byte
+	if requestData.Media != nil {
+		var err error
+		if data, err = base64.StdEncoding.DecodeString(requestData.Media.Data); err != nil {
+			errs = append(errs, FieldError{"media.data", "is not valid base64"})
+		} else if len(data) == 0 {
+			errs = append(errs, FieldError{"media.data", "is required"})
+		} else if len(requestData.Media.MimeType) == 0 {
+			requestData.Media.MimeType = http.DetectContentType(data)
+		}
+
+		if len(requestData.Type) == 0 && len(requestData.Media.MimeType) > 0 {
+			requestData.Type = application.MediaMessageType(requestData.Media.MimeType)
+		}
+	}
+
+	if len(requestData.Type) == 0 {
+		requestData.Type = models.MessageTypeText
+	}
+
+	limit, isMedia := mediaSizeLimits[requestData.Type]
+	switch {
+	case requestData.Type == models.MessageTypeText:
+		if len(strings.TrimSpace(requestData.Text)) == 0 {
+			errs = append(errs, FieldError{"text", "is required"})
+		}
+		if requestData.Media != nil {
+			errs = append(errs, FieldError{"media", "is not allowed for text messages"})
+		}
+	case isMedia:
+		if requestData.Media == nil {
+			errs = append(errs, FieldError{"media", "is required for " + requestData.Type + " messages"})
+		} else if len(data) > limit {
+			errs = append(errs, FieldError{"media.data", fmt.Sprintf("exceeds the %d MB limit of %s messages", limit>>20, requestData.Type)})
+		}
+		if requestData.Type == models.MessageTypeAudio && len(requestData.Text) > 0 {
+			errs = append(errs, FieldError{"text", "audio messages have no caption"})
+		}
+	default:
+		errs = append(errs, FieldError{"type", "must be one of text, image, video, audio or document"})
+	}
+
+	if utf8.RuneCountInString(requestData.Text) > maxTextLength {
+		errs = append(errs, FieldError{"text", fmt.Sprintf("is longer than %d characters", maxTextLength)})
+	}
+
+	if (len(requestData.WindowStart) == 0) != (len(requestData.WindowEnd) == 0) {
+		errs = append(errs, FieldError{"window_end", "window_start and window_end must be given together"})
+	}
+	start, startErr := application.ParseClock(requestData.WindowStart)
+	if len(requestData.WindowStart) > 0 && startErr != nil {
+		errs = append(errs, FieldError{"window_start", "must be a time of day as HH:MM"})
+	}
+	end, endErr := application.ParseClock(requestData.WindowEnd)
+	if len(requestData.WindowEnd) > 0 && endErr != nil {
+		errs = append(errs, FieldError{"window_end", "must be a time of day as HH:MM"})
+	}
+	if len(requestData.WindowStart) > 0 && startErr == nil && endErr == nil && start == end {
+		errs = append(errs, FieldError{"window_end", "must differ from window_start"})
+	}
+
+	return data, errs
+}
@@ -0,0 +1,202 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"gomeow/cmd/models"
+	"gomeow/pkg/phone"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func encodeMedia(data []byte) *sendMessageMedia {
+	return &sendMessageMedia{Data: base64.StdEncoding.EncodeToString(data)}
+}
+
+func TestValidateSendMessageRequest(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	pdf := []byte("%PDF-1.7\n")
+
+	limit := make([]byte, mediaSizeLimits[models.MessageTypeImage])
+	tooLarge := encodeMedia(append(limit, 0)).Data
+
+	tests := []struct {
+		name        string
+		countryCode string
+		request     sendMessageRequest
+		fields      []string
+		to          string
+		messageType string
+		mimeType    string
+	}{
+		{
+			name:        "text to a national number",
+			countryCode: "62",
+			request:     sendMessageRequest{To: " 0812-3456-789 ", Text: "hello"},
+			to:          "628123456789",
+			messageType: models.MessageTypeText,
+		},
+		{
+			name:        "text to a group",
+			request:     sendMessageRequest{To: "120363025246125486@g.us", Text: "hello"},
+			to:          "120363025246125486@g.us",
+			messageType: models.MessageTypeText,
+		},
+		{
+			name:    "national number without a country code",
+			request: sendMessageRequest{To: "0812-3456-789", Text: "hello"},
+			fields:  []string{"to"},
+		},
+		{
+			name:        "invalid phone number",
+			countryCode: "62",
+			request:     sendMessageRequest{To: "0812-CALL-ME", Text: "hello"},
+			fields:      []string{"to"},
+		},
+		{
+			name:    "invalid JID",
+			request: sendMessageRequest{To: "1.2.3@s.whatsapp.net", Text: "hello"},
+			fields:  []string{"to"},
+		},
+		{
+			name:    "every invalid field",
+			request: sendMessageRequest{Text: " "},
+			fields:  []string{"to", "text"},
+		},
+		{
+			name:    "text too long",
+			request: sendMessageRequest{To: "628123456789", Text: strings.Repeat("é", maxTextLength+1)},
+			fields:  []string{"text"},
+		},
+		{
+			name:    "text with media",
+			request: sendMessageRequest{To: "628123456789", Type: models.MessageTypeText, Text: "hello", Media: encodeMedia(png)},
+			fields:  []string{"media"},
+		},
+		{
+			name:    "unknown type",
+			request: sendMessageRequest{To: "628123456789", Type: "sticker", Media: encodeMedia(png)},
+			fields:  []string{"type"},
+		},
+		{
+			name:        "sniffed image",
+			request:     sendMessageRequest{To: "628123456789", Text: "caption", Media: encodeMedia(png)},
+			to:          "628123456789",
+			messageType: models.MessageTypeImage,
+			mimeType:    "image/png",
+		},
+		{
+			name:        "sniffed document",
+			request:     sendMessageRequest{To: "628123456789", Media: encodeMedia(pdf)},
+			to:          "628123456789",
+			messageType: models.MessageTypeDocument,
+			mimeType:    "application/pdf",
+		},
+		{
+			name:        "given MIME type",
+			request:     sendMessageRequest{To: "628123456789", Media: &sendMessageMedia{Data: encodeMedia(pdf).Data, MimeType: "audio/ogg; codecs=opus"}},
+			to:          "628123456789",
+			messageType: models.MessageTypeAudio,
+			mimeType:    "audio/ogg; codecs=opus",
+		},
+		{
+			name:        "given type",
+			request:     sendMessageRequest{To: "628123456789", Type: models.MessageTypeDocument, Media: encodeMedia(png)},
+			to:          "628123456789",
+			messageType: models.MessageTypeDocument,
+			mimeType:    "image/png",
+		},
+		{
+			name:    "audio with a caption",
+			request: sendMessageRequest{To: "628123456789", Type: models.MessageTypeAudio, Text: "caption", Media: encodeMedia(pdf)},
+			fields:  []string{"text"},
+		},
+		{
+			name:    "media type without media",
+			request: sendMessageRequest{To: "628123456789", Type: models.MessageTypeImage},
+			fields:  []string{"media"},
+		},
+		{
+			name:    "invalid base64",
+			request: sendMessageRequest{To: "628123456789", Type: models.MessageTypeImage, Media: &sendMessageMedia{Data: "not base64!"}},
+			fields:  []string{"media.data"},
+		},
+		{
+			name:    "empty media",
+			request: sendMessageRequest{To: "628123456789", Type: models.MessageTypeImage, Media: &sendMessageMedia{}},
+			fields:  []string{"media.data"},
+		},
+		{
+			name:        "image at the size limit",
+			request:     sendMessageRequest{To: "628123456789", Media: &sendMessageMedia{Data: encodeMedia(limit).Data, MimeType: "image/jpeg"}},
+			to:          "628123456789",
+			messageType: models.MessageTypeImage,
+			mimeType:    "image/jpeg",
+		},
+		{
+			name:    "image over the size limit",
+			request: sendMessageRequest{To: "628123456789", Media: &sendMessageMedia{Data: tooLarge, MimeType: "image/jpeg"}},
+			fields:  []string{"media.data"},
+		},
+		{
+			name:        "document over the size limit of images",
+			request:     sendMessageRequest{To: "628123456789", Media: &sendMessageMedia{Data: tooLarge, MimeType: "application/pdf"}},
+			to:          "628123456789",
+			messageType: models.MessageTypeDocument,
+			mimeType:    "application/pdf",
+		},
+		{
+			name:        "window",
+			request:     sendMessageRequest{To: "628123456789", Text: "hello", WindowStart: "22:00", WindowEnd: "06:00"},
+			to:          "628123456789",
+			messageType: models.MessageTypeText,
+		},
+		{
+			name:    "window without its end",
+			request: sendMessageRequest{To: "628123456789", Text: "hello", WindowStart: "08:00"},
+			fields:  []string{"window_end"},
+		},
+		{
+			name:    "window without its start",
+			request: sendMessageRequest{To: "628123456789", Text: "hello", WindowEnd: "17:00"},
+			fields:  []string{"window_end"},
+		},
+		{
+			name:    "invalid window times",
+			request: sendMessageRequest{To: "628123456789", Text: "hello", WindowStart: "25:00", WindowEnd: "5pm"},
+			fields:  []string{"window_start", "window_end"},
+		},
+		{
+			name:    "empty window",
+			request: sendMessageRequest{To: "628123456789", Text: "hello", WindowStart: "08:00", WindowEnd: "08:00"},
+			fields:  []string{"window_end"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := test.request
+			_, errs := validateSendMessageRequest(&request, phone.New(test.countryCode))
+
+			var fields []string
+			for _, err := range errs {
+				fields = append(fields, err.Field)
+			}
+			if !reflect.DeepEqual(fields, test.fields) {
+				t.Fatalf("expected errors of %v, got %v", test.fields, errs)
+			}
+
+			if len(errs) > 0 {
+				return
+			}
+
+			if request.To != test.to || request.Type != test.messageType {
+				t.Errorf("expected a %s message to %s, got a %s message to %s", test.messageType, test.to, request.Type, request.To)
+			}
+
+			if request.Media != nil && request.Media.MimeType != test.mimeType {
+				t.Errorf("expected MIME type %s, got %s", test.mimeType, request.Media.MimeType)
+			}
+		})
+	}
+}
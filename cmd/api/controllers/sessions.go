@@ -0,0 +1,189 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/julienschmidt/httprouter"
+	"go.uber.org/zap"
+	"gomeow/pkg/application"
+	"net/http"
+	"strings"
+)
+
+// HeaderSession chooses the session of a request, the "session" query parameter works as well.
+const HeaderSession = "X-Session"
+
+type sessionRequest struct {
+	Name string `json:"name"`
+}
+
+func SessionIndex(app *application.Application) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		sessions := []application.SessionInfo{}
+		for _, session := range app.Sessions.All() {
+			sessions = append(sessions, session.Info())
+		}
+
+		writeJsonResponse(w, http.StatusOK, "Sessions found", sessions)
+	}
+}
+
+// SessionStore adds a session and starts pairing it.
+func SessionStore(app *application.Application) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		defer r.Body.Close()
+
+		var requestData sessionRequest
+		if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+			writeErrorResponse(w, http.StatusUnprocessableEntity, "Unprocessable Entity")
+			return
+		}
+
+		session, err := app.CreateSession(strings.TrimSpace(requestData.Name))
+		switch {
+		case errors.Is(err, application.ErrInvalidSessionName):
+			writeErrorResponse(w, http.StatusUnprocessableEntity, "Invalid Session Name")
+			return
+		case errors.Is(err, application.ErrSessionExists):
+			writeErrorResponse(w, http.StatusConflict, "Session Already Exists")
+			return
+		case err != nil:
+			zap.S().Errorf(err.Error())
+			writeErrorResponse(w, http.StatusInternalServerError, "Internal Server Error")
+			return
+		}
+
+		if err := session.Meow().StartPairing(); err != nil {
+			writePairingErrorResponse(w, err)
+			return
+		}
+
+		writeJsonResponse(w, http.StatusCreated, "Session created", session.Info())
+	}
+}
+
+func SessionShow(app *application.Application) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		session, ok := findSessionByName(app, w, p)
+		if !ok {
+			return
+		}
+
+		writeJsonResponse(w, http.StatusOK, "Session found", session.Info())
+	}
+}
+
+// SessionLogout unlinks the companion device from the phone.
+func SessionLogout(app *application.Application) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		session, ok := findSessionByName(app, w, p)
+		if !ok {
+			return
+		}
+
+		if err := app.LogoutSession(session); err != nil {
+			writeSessionErrorResponse(w, err)
+			return
+		}
+
+		writeJsonResponse(w, http.StatusOK, "Session logged out", session.Info())
+	}
+}
+
+func SessionReconnect(app *application.Application) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		session, ok := findSessionByName(app, w, p)
+		if !ok {
+			return
+		}
+
+		if err := app.ReconnectSession(session); err != nil {
+			writeSessionErrorResponse(w, err)
+			return
+		}
+
+		writeJsonResponse(w, http.StatusAccepted, "Session reconnecting", session.Info())
+	}
+}
+
+// SessionWipe deletes the device of the session from the device store, so that it can be paired again.
+func SessionWipe(app *application.Application) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		session, ok := findSessionByName(app, w, p)
+		if !ok {
+			return
+		}
+
+		if err := app.WipeSession(session); err != nil {
+			writeSessionErrorResponse(w, err)
+			return
+		}
+
+		writeJsonResponse(w, http.StatusOK, "Session device deleted", session.Info())
+	}
+}
+
+func SessionDelete(app *application.Application) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		session, ok := findSessionByName(app, w, p)
+		if !ok {
+			return
+		}
+
+		if err := app.DeleteSession(session); err != nil {
+			writeSessionErrorResponse(w, err)
+			return
+		}
+
+		writeJsonResponse(w, http.StatusOK, "Session deleted", nil)
+	}
+}
+
+func writeSessionErrorResponse(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, application.ErrNotPaired):
+		writeErrorResponse(w, http.StatusConflict, "Session Is Not Logged In")
+	case errors.Is(err, application.ErrLastSession):
+		writeErrorResponse(w, http.StatusConflict, "The Last Session Can Not Be Deleted")
+	default:
+		zap.S().Errorf(err.Error())
+		writeErrorResponse(w, http.StatusBadGateway, "WhatsApp Request Failed")
+	}
+}
+
+// requestedSession returns the session name or JID chosen by the request, if any.
+func requestedSession(r *http.Request) string {
+	if session := r.Header.Get(HeaderSession); len(session) > 0 {
+		return session
+	}
+
+	return r.URL.Query().Get("session")
+}
+
+// findSession looks up the session chosen by the request, writing a 404 response when there is none.
+func findSession(app *application.Application, w http.ResponseWriter, r *http.Request) (*application.Session, bool) {
+	session, err := app.Sessions.Get(requestedSession(r))
+	if err != nil {
+		writeErrorResponse(w, http.StatusNotFound, "Session Not Found")
+		return nil, false
+	}
+
+	return session, true
+}
+
+// findSessionByName looks up the session of the :name route parameter, writing a 404 response when there is none.
+func findSessionByName(app *application.Application, w http.ResponseWriter, p httprouter.Params) (*application.Session, bool) {
+	name := p.ByName("name")
+	if len(name) == 0 {
+		writeErrorResponse(w, http.StatusNotFound, "Session Not Found")
+		return nil, false
+	}
+
+	session, err := app.Sessions.Get(name)
+	if err != nil {
+		writeErrorResponse(w, http.StatusNotFound, "Session Not Found")
+		return nil, false
+	}
+
+	return session, true
+}
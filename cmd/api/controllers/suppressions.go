@@ -0,0 +1,75 @@
+package controllers
+
+import (
+	"encoding/json"
+	"github.com/julienschmidt/httprouter"
+	"go.uber.org/zap"
+	"gomeow/cmd/models"
+	"gomeow/pkg/application"
+	"net/http"
+	"strings"
+)
+
+type suppressionRequest struct {
+	Phone  string `json:"phone"`
+	Reason string `json:"reason"`
+}
+
+func SuppressionIndex(app *application.Application) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		suppressions := []models.Suppression{}
+		app.MessageStore.Order("id desc").Find(&suppressions)
+
+		writeJsonResponse(w, http.StatusOK, "Suppressions found", suppressions)
+	}
+}
+
+func SuppressionStore(app *application.Application) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		defer r.Body.Close()
+
+		var requestData suppressionRequest
+		if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+			writeErrorResponse(w, http.StatusUnprocessableEntity, "Unprocessable Entity")
+			return
+		}
+
+		phone, err := app.Phone.Normalize(requestData.Phone)
+		if err != nil {
+			writeFieldErrorResponse(w, "Invalid Phone Number", []FieldError{{"phone", "is not a valid phone number"}})
+			return
+		}
+
+		suppression, err := app.Suppress(phone, models.SuppressionSourceApi, requestData.Reason, "")
+		if err != nil {
+			zap.S().Errorf(err.Error())
+			writeErrorResponse(w, http.StatusInternalServerError, "Internal Server Error")
+			return
+		}
+
+		writeJsonResponse(w, http.StatusCreated, "Recipient suppressed", suppression)
+	}
+}
+
+func SuppressionDelete(app *application.Application) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		phone := strings.TrimPrefix(p.ByName("phone"), "+")
+		if normalized, err := app.Phone.Normalize(phone); err == nil {
+			phone = normalized
+		}
+
+		suppression := models.Suppression{}
+		if app.MessageStore.Where("phone = ?", phone).First(&suppression).RecordNotFound() {
+			writeErrorResponse(w, http.StatusNotFound, "Suppression Not Found")
+			return
+		}
+
+		if err := app.Unsuppress(phone); err != nil {
+			zap.S().Errorf(err.Error())
+			writeErrorResponse(w, http.StatusInternalServerError, "Internal Server Error")
+			return
+		}
+
+		writeJsonResponse(w, http.StatusOK, "Suppression removed", suppression)
+	}
+}
@@ -3,7 +3,6 @@ package controllers
 import (
 	"encoding/json"
 	"github.com/julienschmidt/httprouter"
-	"go.mau.fi/whatsmeow"
 	"go.uber.org/zap"
 	"gomeow/pkg/application"
 	"io"
@@ -16,11 +15,16 @@ type arrayOfMessage struct {
 }
 
 type textMessageData struct {
-	Phone   string `json:"phone"`
-	Message string `json:"message"`
-	Secret  bool   `json:"secret"`
-	Retry   bool   `json:"retry"`
-	IsGroup bool   `json:"isGroup"`
+	Phone       string `json:"phone"`
+	Message     string `json:"message"`
+	Secret      bool   `json:"secret"`
+	Retry       bool   `json:"retry"`
+	IsGroup     bool   `json:"isGroup"`
+	Category    string `json:"category"`
+	WindowStart string `json:"windowStart"`
+	WindowEnd   string `json:"windowEnd"`
+	Timezone    string `json:"timezone"`
+	Urgent      bool   `json:"urgent"`
 }
 
 func MessageSend(app *application.Application) httprouter.Handle {
@@ -43,14 +47,14 @@ func MessageSend(app *application.Application) httprouter.Handle {
 			return
 		}
 
-		messageArr := requestData.Data[0]
-
-		// remove first character if it is a '+' sign
-		firstCharacter := messageArr.Phone[0:1]
-		if firstCharacter == "+" {
-			messageArr.Phone = messageArr.Phone[1:]
+		if len(requestData.Data) == 0 {
+			writeErrorResponse(w, 422, "Invalid Parameter Supplied")
+			return
 		}
 
+		// the phone number is normalized when the message is queued
+		messageArr := requestData.Data[0]
+
 		// dump request data
 		zap.S().Debugf("Request Data: %+v", messageArr)
 
@@ -59,29 +63,20 @@ func MessageSend(app *application.Application) httprouter.Handle {
 			return
 		}
 
-		// generate new messageID
-		newMessageId := whatsmeow.GenerateMessageID()
-
-		zap.S().Debugf("Queueing message with ID: %s and content: %s to %s", newMessageId, messageArr.Message, messageArr.Phone)
-
-		pendingMessage := application.PendingMessage{
-			To:        messageArr.Phone,
-			Message:   messageArr.Message,
-			MessageId: newMessageId,
+		pendingMessage, ok := queueMessage(app, w, r, application.PendingMessage{
+			Session:     requestedSession(r),
+			To:          messageArr.Phone,
+			Message:     messageArr.Message,
+			Category:    messageArr.Category,
+			WindowStart: messageArr.WindowStart,
+			WindowEnd:   messageArr.WindowEnd,
+			Timezone:    messageArr.Timezone,
+			Urgent:      messageArr.Urgent,
+		})
+		if !ok {
+			return
 		}
 
-		// store to message store
-		storeToMessageStore(
-			app,
-			app.Meow.DeviceStore.ID.String(),
-			messageArr.Phone,
-			messageArr.Message,
-			newMessageId,
-		)
-
-		// add to queue
-		app.Queue.Add(pendingMessage)
-
 		formattedValues := returnData{
 			Status:  true,
 			Message: "Message queued",
@@ -0,0 +1,110 @@
+package controllers
+
+import (
+	"encoding/json"
+	"github.com/julienschmidt/httprouter"
+	"gomeow/cmd/models"
+	"gomeow/pkg/application"
+	"gomeow/pkg/webhook"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type webhookRequest struct {
+	URL      string   `json:"url"`
+	Secret   string   `json:"secret"`
+	Events   []string `json:"events"`
+	ChatType string   `json:"chat_type"`
+	Keywords []string `json:"keywords"`
+}
+
+type createdWebhook struct {
+	models.Webhook
+	Secret string `json:"secret"`
+}
+
+func WebhookIndex(app *application.Application) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		webhooks := []models.Webhook{}
+		app.MessageStore.Order("id").Find(&webhooks)
+
+		writeJsonResponse(w, http.StatusOK, "Webhooks found", webhooks)
+	}
+}
+
+func WebhookStore(app *application.Application) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		defer r.Body.Close()
+
+		var requestData webhookRequest
+		if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+			writeErrorResponse(w, http.StatusUnprocessableEntity, "Unprocessable Entity")
+			return
+		}
+
+		target, err := url.Parse(requestData.URL)
+		if err != nil || (target.Scheme != "http" && target.Scheme != "https") || len(target.Host) == 0 {
+			writeErrorResponse(w, http.StatusUnprocessableEntity, "Invalid Webhook URL")
+			return
+		}
+
+		if len(requestData.ChatType) > 0 &&
+			requestData.ChatType != models.WebhookChatTypeDirect &&
+			requestData.ChatType != models.WebhookChatTypeGroup {
+			writeErrorResponse(w, http.StatusUnprocessableEntity, "Invalid Chat Type")
+			return
+		}
+
+		if len(requestData.Secret) == 0 {
+			requestData.Secret = webhook.GenerateSecret(32)
+		}
+
+		hook := models.Webhook{
+			URL:      target.String(),
+			Secret:   requestData.Secret,
+			Events:   strings.Join(requestData.Events, ","),
+			ChatType: requestData.ChatType,
+			Keywords: strings.Join(requestData.Keywords, ","),
+			Active:   true,
+		}
+
+		if err := app.MessageStore.Create(&hook).Error; err != nil {
+			writeErrorResponse(w, http.StatusInternalServerError, "Internal Server Error")
+			return
+		}
+
+		// the secret is only shown once
+		writeJsonResponse(w, http.StatusCreated, "Webhook created", createdWebhook{
+			Webhook: hook,
+			Secret:  hook.Secret,
+		})
+	}
+}
+
+func WebhookDelete(app *application.Application) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		hook := models.Webhook{}
+		if app.MessageStore.Where("id = ?", p.ByName("id")).First(&hook).RecordNotFound() {
+			writeErrorResponse(w, http.StatusNotFound, "Webhook Not Found")
+			return
+		}
+
+		app.MessageStore.Delete(&hook)
+
+		writeJsonResponse(w, http.StatusOK, "Webhook deleted", hook)
+	}
+}
+
+func WebhookDeliveries(app *application.Application) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		deliveries := []models.WebhookDelivery{}
+		app.MessageStore.
+			Where("webhook_id = ?", p.ByName("id")).
+			Order("id desc").
+			Limit(100).
+			Find(&deliveries)
+
+		writeJsonResponse(w, http.StatusOK, "Webhook deliveries found", deliveries)
+	}
+}
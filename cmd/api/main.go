@@ -30,9 +30,6 @@ func main() {
 		zap.S().Fatal(err.Error())
 	}
 
-	// Load queue
-	go app.LoadQueue(app.Meow.DeviceStore.ID.String())
-
 	srv := server.
 		Get().
 		WithAddr(app.Cfg.GetAPIPort()).
@@ -55,11 +52,18 @@ func main() {
 		app.RunQueue()
 	}()
 
+	// webhook retry runner
+	// failed deliveries are scheduled in the database, so retries survive a restart
+	go func() {
+		zap.S().Info("starting webhook retry runner")
+		app.Webhooks.RunRetries()
+	}()
+
 	exithandler.Init(func() {
 		if err := srv.Close(); err != nil {
 			zap.S().Error(err.Error())
 		}
 		zap.S().Info("Exiting Application")
-		app.Meow.Exit()
+		app.Exit()
 	})
 }
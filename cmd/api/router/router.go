@@ -3,25 +3,105 @@ package router
 import (
 	"github.com/julienschmidt/httprouter"
 	"gomeow/cmd/api/controllers"
+	"gomeow/cmd/models"
 	"gomeow/pkg/application"
+	"gomeow/pkg/middleware"
+	"gomeow/pkg/ratelimit"
 )
 
 func Get(app *application.Application) *httprouter.Router {
 	mux := httprouter.New()
 
-	// index
-	mux.GET("/api/v1/messages", controllers.MessageIndex(app))
+	send := middleware.RequireScope(app.Keys, models.ApiScopeSend)
+	read := middleware.RequireScope(app.Keys, models.ApiScopeReadMessages)
+	admin := middleware.RequireScope(app.Keys, models.ApiScopeAdmin)
+
+	// clients are rate limited by IP before and by key after authenticating,
+	// signed requests are authenticated before the scope is checked
+	limitIP := middleware.LimitIP(ratelimit.New(app.Cfg.GetRateLimitIP()))
+	signed := middleware.VerifySignature(app.Keys, app.Cfg.GetSignatureWindow())
+	limitKey := middleware.LimitKey(ratelimit.New(app.Cfg.GetRateLimitKey()))
+	protect := func(handle httprouter.Handle, scope middleware.Middleware) httprouter.Handle {
+		return middleware.Chain(handle, limitIP, signed, scope, limitKey)
+	}
+
+	// index, or queueing with the message parameter of the original API
+	mux.GET("/api/v1/messages", controllers.MessageIndex(
+		protect(controllers.MessageList(app), read),
+		protect(controllers.MessageQueue(app), send),
+	))
 
 	// show
+	mux.GET("/api/v1/messages/:id", protect(controllers.MessageShow(app), read))
+	mux.GET("/api/v1/messages/:id/media", protect(controllers.MessageMedia(app), read))
 
 	// store
+	mux.POST("/api/v1/messages", protect(controllers.MessageStore(app), send))
 
 	// update
 
 	// delete
 
+	// event stream
+	mux.GET("/api/v1/events", protect(controllers.EventStream(app), read))
+
+	// contacts, looked up by the session chosen with the X-Session header
+	mux.GET("/api/v1/contacts", protect(controllers.ContactIndex(app), read))
+	mux.GET("/api/v1/contacts/:contact", protect(controllers.ContactShow(app), read))
+	mux.GET("/api/v1/contacts/:contact/picture", protect(controllers.ContactPicture(app), read))
+	mux.POST("/api/v1/contacts/check", protect(controllers.ContactCheck(app), send))
+
+	// chats of the session chosen with the X-Session header, changes act for the session like sends do
+	mux.POST("/api/v1/chats/:chat/read", protect(controllers.ChatRead(app), send))
+	mux.PATCH("/api/v1/chats/:chat", protect(controllers.ChatUpdate(app), send))
+
+	// presence of the session chosen with the X-Session header
+	mux.POST("/api/v1/presence", protect(controllers.PresenceStore(app), send))
+
+	// sessions
+	mux.GET("/api/v1/sessions", protect(controllers.SessionIndex(app), admin))
+	mux.POST("/api/v1/sessions", protect(controllers.SessionStore(app), admin))
+	mux.GET("/api/v1/sessions/:name", protect(controllers.SessionShow(app), admin))
+	mux.POST("/api/v1/sessions/:name/logout", protect(controllers.SessionLogout(app), admin))
+	mux.POST("/api/v1/sessions/:name/reconnect", protect(controllers.SessionReconnect(app), admin))
+	mux.DELETE("/api/v1/sessions/:name/device", protect(controllers.SessionWipe(app), admin))
+	mux.DELETE("/api/v1/sessions/:name", protect(controllers.SessionDelete(app), admin))
+
+	// pairing, of the session chosen with the X-Session header
+	mux.GET("/api/v1/pairing", protect(controllers.PairingShow(app), admin))
+	mux.POST("/api/v1/pairing", protect(controllers.PairingStart(app), admin))
+	mux.GET("/api/v1/pairing/qr", protect(controllers.PairingQR(app), admin))
+	mux.POST("/api/v1/pairing/phone", protect(controllers.PairingPhone(app), admin))
+
+	// auto replies
+	mux.GET("/api/v1/autoreplies", protect(controllers.AutoReplyIndex(app), admin))
+	mux.GET("/api/v1/autoreplies/:id", protect(controllers.AutoReplyShow(app), admin))
+	mux.POST("/api/v1/autoreplies", protect(controllers.AutoReplyStore(app), admin))
+	mux.PUT("/api/v1/autoreplies/:id", protect(controllers.AutoReplyUpdate(app), admin))
+	mux.DELETE("/api/v1/autoreplies/:id", protect(controllers.AutoReplyDelete(app), admin))
+
+	// suppression list
+	mux.GET("/api/v1/suppressions", protect(controllers.SuppressionIndex(app), admin))
+	mux.POST("/api/v1/suppressions", protect(controllers.SuppressionStore(app), admin))
+	mux.DELETE("/api/v1/suppressions/:phone", protect(controllers.SuppressionDelete(app), admin))
+
+	// webhooks
+	mux.GET("/api/v1/webhooks", protect(controllers.WebhookIndex(app), admin))
+	mux.POST("/api/v1/webhooks", protect(controllers.WebhookStore(app), admin))
+	mux.DELETE("/api/v1/webhooks/:id", protect(controllers.WebhookDelete(app), admin))
+	mux.GET("/api/v1/webhooks/:id/deliveries", protect(controllers.WebhookDeliveries(app), admin))
+
+	// api keys
+	mux.GET("/api/v1/keys", protect(controllers.ApiKeyIndex(app), admin))
+	mux.POST("/api/v1/keys", protect(controllers.ApiKeyStore(app), admin))
+	mux.DELETE("/api/v1/keys/:id", protect(controllers.ApiKeyDelete(app), admin))
+	mux.GET("/api/v1/keys/:id/usage", protect(controllers.ApiKeyUsage(app), admin))
+
+	// quota usage of the key making the request
+	mux.GET("/api/v1/usage", protect(controllers.UsageShow(app), send))
+
 	// solo.wablas.com Compatible API
-	mux.POST("/api/v2/send-message", controllers.MessageSend(app))
+	mux.POST("/api/v2/send-message", protect(controllers.MessageSend(app), send))
 
 	return mux
 }
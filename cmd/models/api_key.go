@@ -0,0 +1,76 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// API key scopes
+const (
+	ApiScopeSend         = "send"
+	ApiScopeReadMessages = "read-messages"
+	ApiScopeGroups       = "groups"
+	ApiScopeAdmin        = "admin"
+)
+
+var ApiScopes = []string{ApiScopeSend, ApiScopeReadMessages, ApiScopeGroups, ApiScopeAdmin}
+
+// ApiKey authenticates an API client. Only the SHA-256 hash of the key is stored.
+// Keys with a signing secret can sign requests instead, see middleware.VerifySignature.
+// The quotas limit the messages queued with the key, 0 means unlimited.
+type ApiKey struct {
+	ID            int64      `json:"id" gorm:"auto_increment;primary_key"`
+	Name          string     `json:"name" gorm:"Column:name;type:varchar(255);not null"`
+	Prefix        string     `json:"prefix" gorm:"Column:prefix;type:varchar(16);not null"`
+	Hash          string     `json:"-" gorm:"Column:hash;type:varchar(64);not null;unique"`
+	SigningSecret string     `json:"-" gorm:"Column:signing_secret;type:varchar(64)"`
+	Signing       bool       `json:"signing" gorm:"-"`
+	Scopes        string     `json:"scopes" gorm:"Column:scopes;type:varchar(255);not null"`
+	DailyQuota    int        `json:"daily_quota" gorm:"Column:daily_quota;not null"`
+	MonthlyQuota  int        `json:"monthly_quota" gorm:"Column:monthly_quota;not null"`
+	ExpiresAt     *time.Time `json:"expires_at" gorm:"type:timestamp NULL"`
+	LastUsedAt    *time.Time `json:"last_used_at" gorm:"type:timestamp NULL"`
+	RevokedAt     *time.Time `json:"revoked_at" gorm:"type:timestamp NULL"`
+	CreatedAt     time.Time  `json:"created_at" gorm:"type:timestamp"`
+	UpdatedAt     time.Time  `json:"updated_at" gorm:"type:timestamp"`
+}
+
+func (m *ApiKey) TableName() string {
+	return "whatsmeow_api_keys"
+}
+
+func (m *ApiKey) AfterFind() error {
+	m.Signing = len(m.SigningSecret) > 0
+	return nil
+}
+
+// HasScope checks whether the key grants the scope. Admin keys grant every scope.
+func (m *ApiKey) HasScope(scope string) bool {
+	for _, granted := range strings.Split(m.Scopes, ",") {
+		granted = strings.TrimSpace(granted)
+		if granted == scope || granted == ApiScopeAdmin {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Usable checks that the key is neither revoked nor expired.
+func (m *ApiKey) Usable(now time.Time) bool {
+	return m.RevokedAt == nil && (m.ExpiresAt == nil || now.Before(*m.ExpiresAt))
+}
+
+// ApiKeyUsage counts the messages queued with a key in a day (2006-01-02) or a month (2006-01).
+type ApiKeyUsage struct {
+	ID        int64     `json:"-" gorm:"auto_increment;primary_key"`
+	ApiKeyID  int64     `json:"api_key_id" gorm:"Column:api_key_id;not null;unique_index:idx_api_key_period"`
+	Period    string    `json:"period" gorm:"Column:period;type:varchar(10);not null;unique_index:idx_api_key_period"`
+	Messages  int       `json:"messages" gorm:"Column:messages;not null"`
+	CreatedAt time.Time `json:"created_at" gorm:"type:timestamp"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"type:timestamp"`
+}
+
+func (m *ApiKeyUsage) TableName() string {
+	return "whatsmeow_api_key_usage"
+}
@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// Auto reply match types
+const (
+	AutoReplyMatchExact    = "exact"
+	AutoReplyMatchContains = "contains"
+	AutoReplyMatchRegex    = "regex"
+)
+
+// Auto reply types
+const (
+	AutoReplyTypeText    = "text"
+	AutoReplyTypeMedia   = "media"
+	AutoReplyTypeWebhook = "webhook"
+)
+
+// AutoReplyRule answers incoming messages matching the rule.
+// Rules without a group only apply to direct chats.
+type AutoReplyRule struct {
+	ID              int64     `json:"id" gorm:"auto_increment;primary_key"`
+	Name            string    `json:"name" gorm:"type:varchar(255);not null"`
+	Priority        int       `json:"priority" gorm:"Default:0;index"`
+	MatchType       string    `json:"match_type" gorm:"Column:match_type;type:varchar(16);not null"`
+	Pattern         string    `json:"pattern" gorm:"Column:pattern;type:varchar(1024);not null"`
+	Sender          string    `json:"sender" gorm:"Column:sender;type:varchar(255)"`
+	Group           string    `json:"group" gorm:"Column:group_jid;type:varchar(255)"`
+	TimeFrom        string    `json:"time_from" gorm:"Column:time_from;type:varchar(5)"`
+	TimeTo          string    `json:"time_to" gorm:"Column:time_to;type:varchar(5)"`
+	ReplyType       string    `json:"reply_type" gorm:"Column:reply_type;type:varchar(16);not null"`
+	ReplyText       string    `json:"reply_text" gorm:"Column:reply_text;type:text"`
+	MediaKey        string    `json:"media_key" gorm:"Column:media_key;type:varchar(1024)"`
+	MimeType        string    `json:"mime_type" gorm:"Column:mime_type;type:varchar(255)"`
+	FileName        string    `json:"file_name" gorm:"Column:file_name;type:varchar(255)"`
+	WebhookURL      string    `json:"webhook_url" gorm:"Column:webhook_url;type:varchar(2048)"`
+	CooldownSeconds int       `json:"cooldown_seconds" gorm:"Default:0"`
+	Active          bool      `json:"active"`
+	CreatedAt       time.Time `json:"created_at" gorm:"type:timestamp"`
+	UpdatedAt       time.Time `json:"updated_at" gorm:"type:timestamp"`
+}
+
+func (m *AutoReplyRule) TableName() string {
+	return "whatsmeow_autoreply_rules"
+}
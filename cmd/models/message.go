@@ -2,16 +2,77 @@ package models
 
 import "time"
 
+// Message lifecycle statuses.
+// queued -> sending -> server_acked -> delivered -> read -> played, or failed
+const (
+	MessageStatusQueued      = "queued"
+	MessageStatusSending     = "sending"
+	MessageStatusServerAcked = "server_acked"
+	MessageStatusDelivered   = "delivered"
+	MessageStatusRead        = "read"
+	MessageStatusPlayed      = "played"
+	MessageStatusFailed      = "failed"
+
+	// inbound messages don't move through the lifecycle
+	MessageStatusReceived = "received"
+)
+
+const (
+	MessageDirectionOutbound = "outbound"
+	MessageDirectionInbound  = "inbound"
+)
+
+// Message types
+const (
+	MessageTypeText     = "text"
+	MessageTypeImage    = "image"
+	MessageTypeVideo    = "video"
+	MessageTypeAudio    = "audio"
+	MessageTypeDocument = "document"
+	MessageTypeSticker  = "sticker"
+	MessageTypeReaction = "reaction"
+	MessageTypeLocation = "location"
+	MessageTypeContact  = "contact"
+	MessageTypeUnknown  = "unknown"
+)
+
 type Message struct {
-	ID          int64     `json:"id" gorm:"auto_increment;primary_key"`
-	JID         string    `json:"jid" gorm:"Column:jid;type:varchar(255);not null"`
-	MessageId   string    `json:"message_id" gorm:"Column:message_id;type:varchar(255);not null;unique"`
-	Destination string    `json:"destination" gorm:"not null"`
-	Sent        bool      `json:"sent" gorm:"Default:false"`
-	Read        bool      `json:"read" gorm:"Default:false"`
-	Body        string    `json:"body" gorm:"Column:body;type:text;"`
-	CreatedAt   time.Time `json:"created_at" gorm:"type:timestamp"`
-	UpdatedAt   time.Time `json:"updated_at" gorm:"type:timestamp"`
+	ID            int64      `json:"id" gorm:"auto_increment;primary_key"`
+	JID           string     `json:"jid" gorm:"Column:jid;type:varchar(255);not null;unique_index:idx_jid_message_id"`
+	MessageId     string     `json:"message_id" gorm:"Column:message_id;type:varchar(255);not null;index;unique_index:idx_jid_message_id"`
+	Destination   string     `json:"destination" gorm:"not null"`
+	Direction     string     `json:"direction" gorm:"Column:direction;type:varchar(16);not null;default:'outbound';index"`
+	Type          string     `json:"type" gorm:"Column:type;type:varchar(32);not null;default:'text'"`
+	Sender        string     `json:"sender" gorm:"Column:sender;type:varchar(255);index"`
+	Chat          string     `json:"chat" gorm:"Column:chat;type:varchar(255);index"`
+	PushName      string     `json:"push_name" gorm:"Column:push_name;type:varchar(255)"`
+	Status        string     `json:"status" gorm:"Column:status;type:varchar(32);not null;default:'queued';index"`
+	Attempts      int        `json:"attempts" gorm:"Default:0"`
+	FailureReason string     `json:"failure_reason" gorm:"Column:failure_reason;type:text;"`
+	Body          string     `json:"body" gorm:"Column:body;type:text;"`
+	MimeType      string     `json:"mime_type" gorm:"Column:mime_type;type:varchar(255)"`
+	FileName      string     `json:"file_name" gorm:"Column:file_name;type:varchar(255)"`
+	FileLength    uint64     `json:"file_length" gorm:"Column:file_length"`
+	FileSha256    string     `json:"file_sha256" gorm:"Column:file_sha256;type:varchar(64)"`
+	StorageKey    string     `json:"storage_key" gorm:"Column:storage_key;type:varchar(1024)"`
+	QuotedId      string     `json:"quoted_id" gorm:"Column:quoted_id;type:varchar(255);index"`
+	ReplyToID     *int64     `json:"reply_to_id" gorm:"Column:reply_to_id"`
+	Category      string     `json:"category" gorm:"Column:category;type:varchar(64);index"`
+	WindowStart   string     `json:"window_start" gorm:"Column:window_start;type:varchar(5)"`
+	WindowEnd     string     `json:"window_end" gorm:"Column:window_end;type:varchar(5)"`
+	Timezone      string     `json:"timezone" gorm:"Column:timezone;type:varchar(64)"`
+	Urgent        bool       `json:"urgent" gorm:"Column:urgent"`
+	Timestamp     *time.Time `json:"timestamp" gorm:"Column:message_timestamp;type:timestamp NULL"`
+	QueuedAt      *time.Time `json:"queued_at" gorm:"type:timestamp NULL"`
+	SendingAt     *time.Time `json:"sending_at" gorm:"type:timestamp NULL"`
+	ServerAckedAt *time.Time `json:"server_acked_at" gorm:"type:timestamp NULL"`
+	DeliveredAt   *time.Time `json:"delivered_at" gorm:"type:timestamp NULL"`
+	ReadAt        *time.Time `json:"read_at" gorm:"type:timestamp NULL"`
+	PlayedAt      *time.Time `json:"played_at" gorm:"type:timestamp NULL"`
+	FailedAt      *time.Time `json:"failed_at" gorm:"type:timestamp NULL"`
+	ReceiptSentAt *time.Time `json:"receipt_sent_at" gorm:"type:timestamp NULL"`
+	CreatedAt     time.Time  `json:"created_at" gorm:"type:timestamp"`
+	UpdatedAt     time.Time  `json:"updated_at" gorm:"type:timestamp"`
 }
 
 func (m *Message) TableName() string {
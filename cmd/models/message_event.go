@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// Sources of a message status event
+const (
+	StatusSourceApi          = "api"
+	StatusSourceQueue        = "queue"
+	StatusSourceSendResponse = "send_response"
+	StatusSourceSendError    = "send_error"
+	StatusSourceReceipt      = "receipt"
+	StatusSourceMessage      = "message"
+	StatusSourceSuppression  = "suppression"
+	StatusSourceContactCheck = "contact_check"
+	StatusSourceSession      = "session"
+)
+
+// MessageEvent is an append-only log entry of a message status change.
+// Ignored events didn't change the status of the message, as it was already past it,
+// e.g. the delivery receipts of further group participants, or an ack after a receipt.
+type MessageEvent struct {
+	ID          int64     `json:"id" gorm:"auto_increment;primary_key"`
+	MessageId   string    `json:"message_id" gorm:"Column:message_id;type:varchar(255);not null;index"`
+	Status      string    `json:"status" gorm:"Column:status;type:varchar(32);not null"`
+	Source      string    `json:"source" gorm:"Column:source;type:varchar(64);not null"`
+	Participant string    `json:"participant" gorm:"Column:participant;type:varchar(255)"`
+	Ignored     bool      `json:"ignored" gorm:"Default:false"`
+	OccurredAt  time.Time `json:"occurred_at" gorm:"type:timestamp"`
+	CreatedAt   time.Time `json:"created_at" gorm:"type:timestamp"`
+}
+
+func (m *MessageEvent) TableName() string {
+	return "whatsmeow_message_events"
+}
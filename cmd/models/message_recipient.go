@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// MessageRecipient keeps the receipt state of a single participant
+// for messages sent to a group.
+type MessageRecipient struct {
+	ID          int64      `json:"id" gorm:"auto_increment;primary_key"`
+	MessageId   string     `json:"message_id" gorm:"Column:message_id;type:varchar(255);not null;unique_index:idx_message_participant"`
+	Participant string     `json:"participant" gorm:"Column:participant;type:varchar(255);not null;unique_index:idx_message_participant"`
+	Status      string     `json:"status" gorm:"Column:status;type:varchar(32);not null"`
+	DeliveredAt *time.Time `json:"delivered_at" gorm:"type:timestamp NULL"`
+	ReadAt      *time.Time `json:"read_at" gorm:"type:timestamp NULL"`
+	PlayedAt    *time.Time `json:"played_at" gorm:"type:timestamp NULL"`
+	CreatedAt   time.Time  `json:"created_at" gorm:"type:timestamp"`
+	UpdatedAt   time.Time  `json:"updated_at" gorm:"type:timestamp"`
+}
+
+func (m *MessageRecipient) TableName() string {
+	return "whatsmeow_message_recipients"
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// Session is a WhatsApp number served by the gateway. The JID is empty until the session has been paired.
+type Session struct {
+	ID        int64     `json:"id" gorm:"auto_increment;primary_key"`
+	Name      string    `json:"name" gorm:"Column:name;type:varchar(64);not null;unique"`
+	JID       string    `json:"jid" gorm:"Column:jid;type:varchar(255);index"`
+	CreatedAt time.Time `json:"created_at" gorm:"type:timestamp"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"type:timestamp"`
+}
+
+func (m *Session) TableName() string {
+	return "whatsmeow_sessions"
+}
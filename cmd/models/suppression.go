@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// Suppression sources
+const (
+	SuppressionSourceKeyword = "keyword"
+	SuppressionSourceApi     = "api"
+)
+
+// Suppression is a recipient who opted out of receiving messages.
+type Suppression struct {
+	ID        int64     `json:"id" gorm:"auto_increment;primary_key"`
+	Phone     string    `json:"phone" gorm:"Column:phone;type:varchar(64);not null;unique"`
+	Source    string    `json:"source" gorm:"Column:source;type:varchar(16);not null"`
+	Reason    string    `json:"reason" gorm:"Column:reason;type:varchar(255)"`
+	MessageId string    `json:"message_id" gorm:"Column:message_id;type:varchar(255)"`
+	CreatedAt time.Time `json:"created_at" gorm:"type:timestamp"`
+}
+
+func (m *Suppression) TableName() string {
+	return "whatsmeow_suppressions"
+}
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Webhook is an endpoint that receives gateway events as signed JSON POST requests.
+type Webhook struct {
+	ID        int64     `json:"id" gorm:"auto_increment;primary_key"`
+	URL       string    `json:"url" gorm:"Column:url;type:varchar(2048);not null"`
+	Secret    string    `json:"-" gorm:"Column:secret;type:varchar(255);not null"`
+	Events    string    `json:"events" gorm:"Column:events;type:varchar(1024)"`
+	ChatType  string    `json:"chat_type" gorm:"Column:chat_type;type:varchar(16)"`
+	Keywords  string    `json:"keywords" gorm:"Column:keywords;type:varchar(1024)"`
+	Active    bool      `json:"active" gorm:"Default:true"`
+	CreatedAt time.Time `json:"created_at" gorm:"type:timestamp"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"type:timestamp"`
+}
+
+func (m *Webhook) TableName() string {
+	return "whatsmeow_webhooks"
+}
+
+// Subscribes checks whether the webhook wants to receive the given event.
+// An empty event list or "*" subscribes to every event.
+func (m *Webhook) Subscribes(event string) bool {
+	if len(strings.TrimSpace(m.Events)) == 0 {
+		return true
+	}
+
+	for _, subscribed := range strings.Split(m.Events, ",") {
+		subscribed = strings.TrimSpace(subscribed)
+		if subscribed == "*" || subscribed == event {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Webhook chat type filters
+const (
+	WebhookChatTypeDirect = "direct"
+	WebhookChatTypeGroup  = "group"
+)
+
+// Accepts applies the chat type and keyword filters of the webhook to a message.
+// Keywords are matched case-insensitively anywhere in the message text.
+func (m *Webhook) Accepts(isGroup bool, text string) bool {
+	if m.ChatType == WebhookChatTypeDirect && isGroup {
+		return false
+	}
+
+	if m.ChatType == WebhookChatTypeGroup && !isGroup {
+		return false
+	}
+
+	if len(strings.TrimSpace(m.Keywords)) == 0 {
+		return true
+	}
+
+	text = strings.ToLower(text)
+	for _, keyword := range strings.Split(m.Keywords, ",") {
+		keyword = strings.ToLower(strings.TrimSpace(keyword))
+		if len(keyword) > 0 && strings.Contains(text, keyword) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// WebhookDelivery logs a single delivery attempt of an event to a webhook.
+type WebhookDelivery struct {
+	ID         int64     `json:"id" gorm:"auto_increment;primary_key"`
+	WebhookID  int64     `json:"webhook_id" gorm:"not null;index"`
+	DeliveryId string    `json:"delivery_id" gorm:"Column:delivery_id;type:varchar(64);not null;index"`
+	Event      string    `json:"event" gorm:"Column:event;type:varchar(64);not null"`
+	Attempt    int       `json:"attempt"`
+	Success    bool      `json:"success" gorm:"Default:false"`
+	StatusCode int       `json:"status_code"`
+	Error      string    `json:"error" gorm:"Column:error;type:text;"`
+	DurationMs int64     `json:"duration_ms"`
+	Payload    string    `json:"payload" gorm:"Column:payload;type:text;"`
+	CreatedAt  time.Time `json:"created_at" gorm:"type:timestamp"`
+
+	// NextAttemptAt is set while a failed attempt is waiting to be retried
+	NextAttemptAt *time.Time `json:"next_attempt_at" gorm:"type:timestamp NULL;index"`
+}
+
+func (m *WebhookDelivery) TableName() string {
+	return "whatsmeow_webhook_deliveries"
+}
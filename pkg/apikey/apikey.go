@@ -0,0 +1,181 @@
+package apikey
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/jinzhu/gorm"
+	"go.uber.org/zap"
+	"gomeow/cmd/models"
+	"strings"
+	"sync"
+	"time"
+)
+
+// keyPrefix marks gateway keys, which helps secret scanners and people spot them.
+const keyPrefix = "gmw_"
+
+// lastUsedPrecision limits last used updates to one per key and interval.
+const lastUsedPrecision = time.Minute
+
+var (
+	ErrInvalidKey       = errors.New("invalid api key")
+	ErrInvalidSignature = errors.New("invalid request signature")
+)
+
+// Store issues API keys and authenticates them against their stored hashes.
+type Store struct {
+	db        *gorm.DB
+	bootstrap string
+	location  *time.Location
+
+	mu       sync.Mutex
+	lastUsed map[int64]time.Time
+}
+
+// NewStore returns a key store. The bootstrap key, if any, is accepted as an admin key
+// without being stored, so that the first keys can be issued. Quota periods start at midnight in the location.
+func NewStore(db *gorm.DB, bootstrap string, location *time.Location) *Store {
+	return &Store{
+		db:        db,
+		bootstrap: bootstrap,
+		location:  location,
+		lastUsed:  make(map[int64]time.Time),
+	}
+}
+
+// Issue stores the key with a new hash, returning the plain key, which isn't stored anywhere.
+// Signing keys also get a secret to sign requests with.
+func (s *Store) Issue(key *models.ApiKey) (string, error) {
+	plain, err := randomHex(24)
+	if err != nil {
+		return "", err
+	}
+	plain = keyPrefix + plain
+
+	key.Prefix = plain[:len(keyPrefix)+6]
+	key.Hash = Hash(plain)
+
+	if key.Signing {
+		if key.SigningSecret, err = randomHex(32); err != nil {
+			return "", err
+		}
+	}
+
+	if err := s.db.Create(key).Error; err != nil {
+		return "", err
+	}
+
+	return plain, nil
+}
+
+// Revoke stops the key from authenticating.
+func (s *Store) Revoke(key *models.ApiKey) error {
+	now := time.Now()
+	key.RevokedAt = &now
+
+	return s.db.Model(key).UpdateColumn("revoked_at", now).Error
+}
+
+// Authenticate returns the usable key matching the plain key.
+func (s *Store) Authenticate(plain string) (*models.ApiKey, error) {
+	if len(plain) == 0 {
+		return nil, ErrInvalidKey
+	}
+
+	if len(s.bootstrap) > 0 && subtle.ConstantTimeCompare([]byte(plain), []byte(s.bootstrap)) == 1 {
+		return &models.ApiKey{Name: "bootstrap", Prefix: "API_TOKEN", Scopes: models.ApiScopeAdmin}, nil
+	}
+
+	key := models.ApiKey{}
+	if err := s.db.Where("hash = ?", Hash(plain)).First(&key).Error; err != nil {
+		return nil, lookupError(err)
+	}
+
+	now := time.Now()
+	if !key.Usable(now) {
+		return nil, ErrInvalidKey
+	}
+
+	s.touch(&key, now)
+
+	return &key, nil
+}
+
+// AuthenticateSignature returns the usable signing key with the id, if the signature of the payload matches.
+func (s *Store) AuthenticateSignature(id string, payload string, signature string) (*models.ApiKey, error) {
+	key := models.ApiKey{}
+	if err := s.db.Where("id = ?", id).First(&key).Error; err != nil {
+		return nil, lookupError(err)
+	}
+
+	if !key.Signing {
+		return nil, ErrInvalidKey
+	}
+
+	now := time.Now()
+	if !key.Usable(now) {
+		return nil, ErrInvalidKey
+	}
+
+	if !hmac.Equal([]byte(Sign(key.SigningSecret, payload)), []byte(strings.ToLower(signature))) {
+		return nil, ErrInvalidSignature
+	}
+
+	s.touch(&key, now)
+
+	return &key, nil
+}
+
+// lookupError tells unknown keys apart from failed lookups, which must not authenticate anyone either.
+func lookupError(err error) error {
+	if gorm.IsRecordNotFoundError(err) {
+		return ErrInvalidKey
+	}
+
+	return fmt.Errorf("failed to look up the api key: %w", err)
+}
+
+func (s *Store) touch(key *models.ApiKey, now time.Time) {
+	s.mu.Lock()
+	last, ok := s.lastUsed[key.ID]
+	if ok && now.Sub(last) < lastUsedPrecision {
+		s.mu.Unlock()
+		return
+	}
+	s.lastUsed[key.ID] = now
+	s.mu.Unlock()
+
+	key.LastUsedAt = &now
+	go func(id int64) {
+		if err := s.db.Model(&models.ApiKey{}).Where("id = ?", id).UpdateColumn("last_used_at", now).Error; err != nil {
+			zap.S().Errorf("Failed to update last use of api key %d: %s", id, err)
+		}
+	}(key.ID)
+}
+
+// Hash returns the hex encoded SHA-256 of the key. Keys are random enough not to need a slow hash.
+func Hash(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of the payload.
+func Sign(secret string, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func randomHex(size int) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
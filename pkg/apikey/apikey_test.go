@@ -0,0 +1,66 @@
+package apikey
+
+import (
+	"errors"
+	"gomeow/cmd/models"
+	"gomeow/pkg/testdb"
+	"testing"
+	"time"
+)
+
+func TestAuthenticate(t *testing.T) {
+	store := newTestStore(t)
+
+	key := &models.ApiKey{Name: "test", Scopes: models.ApiScopeSend}
+	plain, err := store.Issue(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	revoked := &models.ApiKey{Name: "revoked", Scopes: models.ApiScopeSend}
+	revokedPlain, err := store.Issue(revoked)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Revoke(revoked); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		plain string
+		id    int64
+		err   error
+	}{
+		{"valid", plain, key.ID, nil},
+		{"empty", "", 0, ErrInvalidKey},
+		{"unknown", "gmw_unknown", 0, ErrInvalidKey},
+		{"revoked", revokedPlain, 0, ErrInvalidKey},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			authenticated, err := store.Authenticate(test.plain)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("expected error %v, got %v", test.err, err)
+			}
+
+			if err == nil && authenticated.ID != test.id {
+				t.Errorf("expected key %d, got %d", test.id, authenticated.ID)
+			}
+		})
+	}
+}
+
+func TestAuthenticateFailedLookup(t *testing.T) {
+	// without the api key table every lookup fails
+	store := NewStore(testdb.Open(t), "", time.UTC)
+
+	if key, err := store.Authenticate("gmw_unknown"); err == nil || errors.Is(err, ErrInvalidKey) {
+		t.Errorf("expected the lookup error, got %v with key %v", err, key)
+	}
+
+	if key, err := store.AuthenticateSignature("1", "payload", "signature"); err == nil || errors.Is(err, ErrInvalidKey) {
+		t.Errorf("expected the lookup error, got %v with key %v", err, key)
+	}
+}
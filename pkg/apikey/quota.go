@@ -0,0 +1,148 @@
+package apikey
+
+import (
+	"errors"
+	"github.com/jinzhu/gorm"
+	"gomeow/cmd/models"
+	"time"
+)
+
+var ErrQuotaExceeded = errors.New("message quota exceeded")
+
+// Period formats of the usage rows
+const (
+	dayPeriod   = "2006-01-02"
+	monthPeriod = "2006-01"
+)
+
+type QuotaUsage struct {
+	Period    string    `json:"period"`
+	Messages  int       `json:"messages"`
+	Quota     int       `json:"quota"`
+	Remaining *int      `json:"remaining"`
+	ResetsAt  time.Time `json:"resets_at"`
+}
+
+type Usage struct {
+	Daily   QuotaUsage           `json:"daily"`
+	Monthly QuotaUsage           `json:"monthly"`
+	History []models.ApiKeyUsage `json:"history"`
+}
+
+type quotaPeriod struct {
+	period   string
+	quota    int
+	resetsAt time.Time
+}
+
+func (s *Store) periods(key *models.ApiKey, now time.Time) []quotaPeriod {
+	now = now.In(s.location)
+	year, month, day := now.Date()
+
+	return []quotaPeriod{
+		{now.Format(dayPeriod), key.DailyQuota, time.Date(year, month, day+1, 0, 0, 0, 0, s.location)},
+		{now.Format(monthPeriod), key.MonthlyQuota, time.Date(year, month+1, 1, 0, 0, 0, 0, s.location)},
+	}
+}
+
+// Reserve counts the messages against the quotas of the key. When a quota doesn't allow them,
+// nothing is counted and ErrQuotaExceeded is returned with the time until the quota resets.
+// The bootstrap key has no quota.
+func (s *Store) Reserve(key *models.ApiKey, messages int) (time.Duration, error) {
+	if key.ID == 0 {
+		return 0, nil
+	}
+
+	now := time.Now()
+	periods := s.periods(key, now)
+
+	// rows are created outside the transaction, as concurrent requests may race to create them
+	for _, p := range periods {
+		if err := s.ensureUsage(key.ID, p.period); err != nil {
+			return 0, err
+		}
+	}
+
+	tx := s.db.Begin()
+	for _, p := range periods {
+		update := tx.Model(&models.ApiKeyUsage{}).Where("api_key_id = ? AND period = ?", key.ID, p.period)
+		if p.quota > 0 {
+			update = update.Where("messages + ? <= ?", messages, p.quota)
+		}
+
+		result := update.UpdateColumn("messages", gorm.Expr("messages + ?", messages))
+		if result.Error != nil {
+			tx.Rollback()
+			return 0, result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			tx.Rollback()
+			return p.resetsAt.Sub(now), ErrQuotaExceeded
+		}
+	}
+
+	return 0, tx.Commit().Error
+}
+
+// Release gives back reserved messages that haven't been queued after all.
+func (s *Store) Release(key *models.ApiKey, messages int) error {
+	if key.ID == 0 {
+		return nil
+	}
+
+	for _, p := range s.periods(key, time.Now()) {
+		err := s.db.Model(&models.ApiKeyUsage{}).
+			Where("api_key_id = ? AND period = ? AND messages >= ?", key.ID, p.period, messages).
+			UpdateColumn("messages", gorm.Expr("messages - ?", messages)).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Usage returns the usage of the current day and month, and the usage rows of the last days and months.
+func (s *Store) Usage(key *models.ApiKey) Usage {
+	periods := s.periods(key, time.Now())
+	usage := Usage{History: []models.ApiKeyUsage{}}
+
+	s.db.Where("api_key_id = ?", key.ID).Order("period desc").Limit(100).Find(&usage.History)
+
+	current := []*QuotaUsage{&usage.Daily, &usage.Monthly}
+	for i, p := range periods {
+		*current[i] = QuotaUsage{Period: p.period, Quota: p.quota, ResetsAt: p.resetsAt}
+		for _, row := range usage.History {
+			if row.Period == p.period {
+				current[i].Messages = row.Messages
+			}
+		}
+
+		if p.quota > 0 {
+			remaining := p.quota - current[i].Messages
+			if remaining < 0 {
+				remaining = 0
+			}
+			current[i].Remaining = &remaining
+		}
+	}
+
+	return usage
+}
+
+func (s *Store) ensureUsage(id int64, period string) error {
+	usage := models.ApiKeyUsage{}
+	if !s.db.Where("api_key_id = ? AND period = ?", id, period).First(&usage).RecordNotFound() {
+		return nil
+	}
+
+	if err := s.db.Create(&models.ApiKeyUsage{ApiKeyID: id, Period: period}).Error; err != nil {
+		// another request may have created it in the meantime
+		if s.db.Where("api_key_id = ? AND period = ?", id, period).First(&usage).RecordNotFound() {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,170 @@
+package apikey
+
+import (
+	"errors"
+	"gomeow/cmd/models"
+	"gomeow/pkg/testdb"
+	"testing"
+	"time"
+)
+
+func newTestStore(t *testing.T) *Store {
+	return NewStore(testdb.Open(t, &models.ApiKey{}, &models.ApiKeyUsage{}), "", time.UTC)
+}
+
+func TestPeriods(t *testing.T) {
+	jakarta := time.FixedZone("WIB", 7*60*60)
+	store := &Store{location: jakarta}
+	key := &models.ApiKey{DailyQuota: 10, MonthlyQuota: 100}
+
+	tests := []struct {
+		name          string
+		now           time.Time
+		day           string
+		dayResetsAt   time.Time
+		month         string
+		monthResetsAt time.Time
+	}{
+		{
+			name:          "middle of the month",
+			now:           time.Date(2024, 5, 15, 3, 0, 0, 0, time.UTC),
+			day:           "2024-05-15",
+			dayResetsAt:   time.Date(2024, 5, 16, 0, 0, 0, 0, jakarta),
+			month:         "2024-05",
+			monthResetsAt: time.Date(2024, 6, 1, 0, 0, 0, 0, jakarta),
+		},
+		{
+			name:          "already the next day in the location",
+			now:           time.Date(2024, 5, 31, 18, 0, 0, 0, time.UTC),
+			day:           "2024-06-01",
+			dayResetsAt:   time.Date(2024, 6, 2, 0, 0, 0, 0, jakarta),
+			month:         "2024-06",
+			monthResetsAt: time.Date(2024, 7, 1, 0, 0, 0, 0, jakarta),
+		},
+		{
+			name:          "end of the year",
+			now:           time.Date(2024, 12, 31, 16, 59, 0, 0, time.UTC),
+			day:           "2024-12-31",
+			dayResetsAt:   time.Date(2025, 1, 1, 0, 0, 0, 0, jakarta),
+			month:         "2024-12",
+			monthResetsAt: time.Date(2025, 1, 1, 0, 0, 0, 0, jakarta),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			periods := store.periods(key, test.now)
+
+			if periods[0].period != test.day || !periods[0].resetsAt.Equal(test.dayResetsAt) {
+				t.Errorf("expected day %s resetting at %s, got %s resetting at %s", test.day, test.dayResetsAt, periods[0].period, periods[0].resetsAt)
+			}
+
+			if periods[1].period != test.month || !periods[1].resetsAt.Equal(test.monthResetsAt) {
+				t.Errorf("expected month %s resetting at %s, got %s resetting at %s", test.month, test.monthResetsAt, periods[1].period, periods[1].resetsAt)
+			}
+		})
+	}
+}
+
+func TestReserve(t *testing.T) {
+	type reservation struct {
+		messages int
+		release  bool
+		exceeded bool
+	}
+
+	tests := []struct {
+		name         string
+		dailyQuota   int
+		monthlyQuota int
+		reservations []reservation
+		daily        int
+		monthly      int
+	}{
+		{
+			name:         "unlimited",
+			reservations: []reservation{{messages: 5}, {messages: 5}},
+			daily:        10,
+			monthly:      10,
+		},
+		{
+			name:         "up to the quota",
+			dailyQuota:   4,
+			monthlyQuota: 4,
+			reservations: []reservation{{messages: 2}, {messages: 2}, {messages: 1, exceeded: true}},
+			daily:        4,
+			monthly:      4,
+		},
+		{
+			name:         "daily quota exceeded",
+			dailyQuota:   3,
+			monthlyQuota: 100,
+			reservations: []reservation{{messages: 2}, {messages: 2, exceeded: true}},
+			daily:        2,
+			monthly:      2,
+		},
+		{
+			name:         "monthly quota exceeded rolls back the day",
+			dailyQuota:   10,
+			monthlyQuota: 3,
+			reservations: []reservation{{messages: 2}, {messages: 2, exceeded: true}},
+			daily:        2,
+			monthly:      2,
+		},
+		{
+			name:         "released messages count again",
+			dailyQuota:   3,
+			monthlyQuota: 3,
+			reservations: []reservation{{messages: 3}, {messages: 2, release: true}, {messages: 2}},
+			daily:        3,
+			monthly:      3,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := newTestStore(t)
+			key := &models.ApiKey{Name: "test", Scopes: models.ApiScopeSend, DailyQuota: test.dailyQuota, MonthlyQuota: test.monthlyQuota}
+			if _, err := store.Issue(key); err != nil {
+				t.Fatal(err)
+			}
+
+			for i, r := range test.reservations {
+				if r.release {
+					if err := store.Release(key, r.messages); err != nil {
+						t.Fatalf("reservation %d: %s", i, err)
+					}
+					continue
+				}
+
+				retryAfter, err := store.Reserve(key, r.messages)
+				if r.exceeded {
+					if !errors.Is(err, ErrQuotaExceeded) || retryAfter <= 0 {
+						t.Fatalf("reservation %d: expected the quota to be exceeded, got %v after %s", i, err, retryAfter)
+					}
+					continue
+				}
+
+				if err != nil {
+					t.Fatalf("reservation %d: %s", i, err)
+				}
+			}
+
+			usage := store.Usage(key)
+			if usage.Daily.Messages != test.daily || usage.Monthly.Messages != test.monthly {
+				t.Errorf("expected %d/%d messages, got %d/%d", test.daily, test.monthly, usage.Daily.Messages, usage.Monthly.Messages)
+			}
+		})
+	}
+}
+
+func TestReserveBootstrapKey(t *testing.T) {
+	store := newTestStore(t)
+	key := &models.ApiKey{Name: "bootstrap", Scopes: models.ApiScopeAdmin, DailyQuota: 1}
+
+	for i := 0; i < 3; i++ {
+		if _, err := store.Reserve(key, 1); err != nil {
+			t.Fatalf("expected the bootstrap key to have no quota, got %s", err)
+		}
+	}
+}
@@ -1,82 +1,300 @@
 package application
 
 import (
-	"container/list"
+	"errors"
+	"fmt"
 	"github.com/jinzhu/gorm"
+	"go.mau.fi/whatsmeow"
 	"go.mau.fi/whatsmeow/store/sqlstore"
 	"go.mau.fi/whatsmeow/types/events"
 	"go.uber.org/zap"
 	"gomeow/cmd/models"
+	"gomeow/pkg/apikey"
 	"gomeow/pkg/config"
-	"gomeow/pkg/queues"
+	"gomeow/pkg/dispatcher"
+	"gomeow/pkg/mailer"
+	"gomeow/pkg/phone"
+	"gomeow/pkg/storage"
+	"gomeow/pkg/stream"
+	"gomeow/pkg/webhook"
+	"strings"
 	"time"
 )
 
 type Application struct {
 	Cfg          *config.Config
-	Meow         *Meow
+	Sessions     *SessionRegistry
 	DB           *sqlstore.Container
 	MessageStore *gorm.DB
-	Queue        *queues.Queue
+	Webhooks     *webhook.Dispatcher
+	Storage      storage.Storage
+	Stream       *stream.Hub
+	AutoReply    *AutoReply
+	Mailer       *mailer.Mailer
+	Humanizer    *Humanizer
+	Keys         *apikey.Store
+	Phone        *phone.Normalizer
+
+	location        *time.Location
+	deliveryWindows map[string]DeliveryWindow
+	contacts        *contactCache
+	preSendCheck    bool
 }
 
 func Start() (*Application, error) {
 	cfg := config.Get()
 	zap.S().Info("Starting application")
 	db := cfg.ConnectToDatabase()
-	queue := queues.InitQueue()
 	msgStore := cfg.ConnectToMessageStore()
-	waEngine := Init(cfg, db, msgStore)
+	webhooks := webhook.NewDispatcher(
+		msgStore,
+		cfg.GetWebhookMaxAttempts(),
+		cfg.GetWebhookBackoff(),
+		cfg.GetWebhookTimeout(),
+	)
+	mediaStorage := cfg.ConnectToStorage()
+	hub := stream.NewHub(cfg.GetStreamHistorySize())
 
 	// run automigration
 	zap.S().Debug("Running auto migration")
-	msgStore.AutoMigrate(&models.Message{})
-
-	waEngine.Connect()
+	msgStore.AutoMigrate(
+		&models.Message{},
+		&models.MessageRecipient{},
+		&models.MessageEvent{},
+		&models.Webhook{},
+		&models.WebhookDelivery{},
+		&models.AutoReplyRule{},
+		&models.Suppression{},
+		&models.Session{},
+		&models.ApiKey{},
+		&models.ApiKeyUsage{},
+	)
+	migrateLegacyMessageFlags(msgStore)
+	migrateMessageIdIndex(msgStore)
 
-	return &Application{
+	app := &Application{
 		Cfg:          cfg,
 		DB:           db,
-		Meow:         waEngine,
-		Queue:        queue,
+		Sessions:     NewSessionRegistry(),
 		MessageStore: msgStore,
-	}, nil
+		Webhooks:     webhooks,
+		Storage:      mediaStorage,
+		Stream:       hub,
+		Mailer:       cfg.GetMailer(),
+		Keys:         apikey.NewStore(msgStore, cfg.GetAPIToken(), cfg.GetLocation()),
+		Phone:        phone.New(cfg.GetDefaultCountryCode()),
+
+		location:        cfg.GetLocation(),
+		deliveryWindows: ParseDeliveryWindows(cfg.GetDeliveryWindows()),
+		contacts:        newContactCache(cfg.GetContactCheckTTL()),
+		preSendCheck:    cfg.GetPreSendCheck(),
+	}
+
+	if cfg.GetHumanizeSending() {
+		humanizer, err := NewHumanizer(cfg.GetTypingSpeed(), cfg.GetTypingMaxDelay(), cfg.GetPresenceAfterSend())
+		if err != nil {
+			return nil, fmt.Errorf("PRESENCE_AFTER_SEND must be available, unavailable or empty: %w", err)
+		}
+		app.Humanizer = humanizer
+	}
+
+	if cfg.GetAutoReplyEnabled() {
+		app.AutoReply = NewAutoReply(msgStore, app.QueueMessage, app.location, cfg.GetAutoReplyCooldown())
+	}
+
+	app.loadSessions()
+	for _, session := range app.Sessions.All() {
+		// paired sessions load their queue before any request is served,
+		// the others once their device is paired and connected
+		if len(session.JID()) > 0 && session.queueLoaded.CompareAndSwap(false, true) {
+			app.LoadQueue(session)
+		}
+
+		session.Meow().Connect()
+	}
+
+	return app, nil
 }
 
-func (app *Application) AddReadEventHandler(evt interface{}) {
-	switch v := evt.(type) {
-	case *events.Receipt:
-		if v.Type == events.ReceiptTypeRead {
-			zap.S().Debugf("Received a read receipt [%s]", v.MessageIDs)
+// Exit disconnects every session.
+func (app *Application) Exit() {
+	for _, session := range app.Sessions.All() {
+		session.Meow().Exit()
+	}
+}
+
+// eventHandler handles the events of the client of the session. Once the device of the session
+// has been replaced, late events of the old client, e.g. a LoggedOut, are ignored.
+func (app *Application) eventHandler(session *Session, meow *Meow) dispatcher.Handler {
+	return func(evt interface{}) {
+		if session.Meow() != meow {
+			return
+		}
+
+		switch v := evt.(type) {
+		case *events.PairSuccess:
+			app.MessageStore.Model(&models.Session{}).Where("name = ?", session.Name).Update("jid", v.ID.String())
+
+		case *events.StreamReplaced, *events.TemporaryBan, *events.ConnectFailure:
+			go app.alertConnection(session, meow.Pairing().JID, meow.Connection())
 
+		case *events.LoggedOut:
+			go app.alertConnection(session, meow.Pairing().JID, meow.Connection())
+
+			// whatsmeow deleted the device, the session needs a new one to pair again
+			app.resetSession(session)
+
+		case *events.Connected:
+			// the device may have been paired just now, so its JID is only known once connected
+			if session.queueLoaded.CompareAndSwap(false, true) {
+				go app.LoadQueue(session)
+			}
+
+		case *events.Message:
 			go func() {
-				for _, messageId := range v.MessageIDs {
-					app.MessageStore.Model(&models.Message{}).
-						Where("message_id = ?", messageId).
-						Update("read", true)
+				// opt-out keywords are never answered by auto replies
+				if app.handleOptOut(v) || app.AutoReply == nil {
+					return
 				}
+
+				app.AutoReply.Handle(session.Name, v)
 			}()
 		}
 	}
 }
 
-func (app *Application) LoadQueue(jid string) {
-	zap.S().Info("Loading queue")
+// QueueMessage stores a new outgoing message in the message store and adds it to the queue
+// of the session chosen by the message, or of the default session.
+func (app *Application) QueueMessage(message PendingMessage) (PendingMessage, error) {
+	session, err := app.Sessions.Get(message.Session)
+	if err != nil {
+		return message, err
+	}
+	message.Session = session.Name
+
+	if len(session.JID()) == 0 {
+		return message, ErrNotPaired
+	}
+
+	if len(message.MessageId) == 0 {
+		message.MessageId = whatsmeow.GenerateMessageID()
+	}
+
+	if len(message.Type) == 0 {
+		message.Type = models.MessageTypeText
+	}
+
+	// phone numbers are normalized before they become JIDs, full JIDs are taken as they are
+	if !strings.Contains(message.To, "@") {
+		if message.To, err = app.Phone.Normalize(message.To); err != nil {
+			return message, err
+		}
+	}
+
+	chat, err := recipientJID(message.To)
+	if err != nil {
+		return message, err
+	}
+
+	if err := validateDelivery(message); err != nil {
+		return message, err
+	}
+
+	if app.IsSuppressed(message.To) {
+		return message, ErrRecipientSuppressed
+	}
+
+	// offline sessions and failed lookups leave the check to SendMeow
+	if app.preSendCheck && session.Meow().Online() {
+		canonical, err := app.checkRecipient(session, chat)
+		if errors.Is(err, ErrNotOnWhatsApp) {
+			return message, err
+		}
+		if err == nil && canonical != chat {
+			chat = canonical
+			message.To = canonical.User
+		}
+	}
+
+	zap.S().Debugf("Queueing message with ID: %s and content: %s to %s", message.MessageId, message.Message, message.To)
+
+	queuedAt := time.Now()
+	storedMessage := models.Message{
+		JID:         session.JID(),
+		Destination: message.To,
+		Chat:        chat.String(),
+		Direction:   models.MessageDirectionOutbound,
+		Type:        message.Type,
+		MessageId:   message.MessageId,
+		Body:        message.Message,
+		MimeType:    message.MimeType,
+		FileName:    message.FileName,
+		StorageKey:  message.MediaKey,
+		Category:    message.Category,
+		WindowStart: message.WindowStart,
+		WindowEnd:   message.WindowEnd,
+		Timezone:    message.Timezone,
+		Urgent:      message.Urgent,
+		Status:      models.MessageStatusQueued,
+		QueuedAt:    &queuedAt,
+	}
+
+	// store to message store
+	if err := app.MessageStore.Create(&storedMessage).Error; err != nil {
+		return message, err
+	}
+	session.Meow().RecordMessageEvent(message.MessageId, models.MessageStatusQueued, queuedAt, models.StatusSourceApi, "")
+
+	// add to queue
+	session.Queue.Add(message)
+
+	return message, nil
+}
+
+func (app *Application) LoadQueue(session *Session) {
+	zap.S().Infof("Loading queue of session %s", session.Name)
+	jid := session.JID()
 
 	var messages []models.Message
-	app.MessageStore.Where("sent = ? AND jid = ?", "0", jid).Find(&messages)
+	app.MessageStore.
+		Where("direction = ? AND status IN (?) AND jid = ?", models.MessageDirectionOutbound, []string{models.MessageStatusQueued, models.MessageStatusSending}, jid).
+		Find(&messages)
 
 	zap.S().Info("Found ", len(messages), " messages to send")
 
 	for _, message := range messages {
+		// messages queued through the API while loading are already in the queue
+		queued := session.Queue.Contains(func(pending interface{}) bool {
+			return pending.(PendingMessage).MessageId == message.MessageId
+		})
+		if queued {
+			continue
+		}
+
+		if app.IsSuppressed(message.Destination) {
+			zap.S().Infof("Not loading message %s, %s has opted out", message.MessageId, message.Destination)
+			app.failMessage(session, message.MessageId, models.StatusSourceSuppression, ErrRecipientSuppressed)
+			continue
+		}
 
 		pendingMessage := PendingMessage{
-			To:        message.Destination,
-			MessageId: message.MessageId,
-			Message:   message.Body,
+			Session:     session.Name,
+			To:          message.Destination,
+			MessageId:   message.MessageId,
+			Message:     message.Body,
+			Type:        message.Type,
+			MediaKey:    message.StorageKey,
+			MimeType:    message.MimeType,
+			FileName:    message.FileName,
+			Category:    message.Category,
+			WindowStart: message.WindowStart,
+			WindowEnd:   message.WindowEnd,
+			Timezone:    message.Timezone,
+			Urgent:      message.Urgent,
+			Attempts:    message.Attempts,
 		}
 
-		app.Queue.Add(pendingMessage)
+		session.Queue.Add(pendingMessage)
 	}
 }
 
@@ -92,7 +310,9 @@ func (app *Application) RunQueue() {
 		select {
 		case <-ticker.C:
 			// ToDo: Implement other commands
-			go app.SendMeow()
+			for _, session := range app.Sessions.All() {
+				go app.SendMeow(session)
+			}
 		case <-quit:
 			ticker.Stop()
 			return
@@ -100,43 +320,126 @@ func (app *Application) RunQueue() {
 	}
 }
 
-func (app *Application) SendMeow() {
-	messageLength := app.Queue.Messages.Len()
+func (app *Application) SendMeow(session *Session) {
+	// humanized sends take seconds, one at a time like a person typing
+	if app.Humanizer != nil {
+		if !session.sending.TryLock() {
+			return
+		}
+		defer session.sending.Unlock()
+	}
+
+	messageLength := session.Queue.Len()
+
+	// keep the queue while offline instead of burning send attempts
+	if messageLength > 0 && !session.Meow().Online() {
+		zap.S().Debugf("Session %s is %s, holding %d queued messages", session.Name, session.Meow().Connection().State, messageLength)
+		return
+	}
 
 	if messageLength > 0 {
-		zap.S().Debugf("Queue length: %d", messageLength)
-		e := app.Queue.Messages.Front()
-		value := e.Value
+		zap.S().Debugf("Queue length of session %s: %d", session.Name, messageLength)
 
-		// remove from queue and database
-		app.RemoveFromQueue(e)
+		// messages outside of their delivery window are held, keeping their place in the queue
+		now := time.Now()
+		next := session.Queue.Take(func(message interface{}) bool {
+			return app.readyToSend(session, message.(PendingMessage), now)
+		})
+		if next == nil {
+			return
+		}
+		pendingMessage := next.(PendingMessage)
+
+		// the recipient may have opted out since the message has been queued
+		if app.IsSuppressed(pendingMessage.To) {
+			zap.S().Infof("Dropping message %s, %s has opted out", pendingMessage.MessageId, pendingMessage.To)
+			app.MarkAsFailed(session, pendingMessage, models.StatusSourceSuppression, ErrRecipientSuppressed)
+			return
+		}
 
-		err := app.Meow.SendMessage(value.(PendingMessage))
+		if app.preSendCheck {
+			if err := app.checkPendingRecipient(session, pendingMessage); errors.Is(err, ErrNotOnWhatsApp) {
+				zap.S().Infof("Dropping message %s, %s is not on WhatsApp", pendingMessage.MessageId, pendingMessage.To)
+				app.MarkAsFailed(session, pendingMessage, models.StatusSourceContactCheck, err)
+				return
+			}
+		}
+
+		app.MarkAsSending(session, pendingMessage)
+		if app.Humanizer != nil {
+			app.Humanizer.beforeSend(session.Meow(), pendingMessage)
+		}
+		resp, err := session.Meow().SendMessage(pendingMessage)
+		if app.Humanizer != nil {
+			app.Humanizer.afterSend(session.Meow())
+		}
 
 		// Requeue if error happens.
 		if err != nil {
+			// the connection dropped while sending, which isn't the fault of the message
+			if !session.Meow().Online() {
+				zap.S().Warnf("Error Sending Message: %s. Session %s went offline, pushing message back to queue", err.Error(), session.Name)
+				session.Meow().UpdateMessageStatus(pendingMessage.MessageId, models.MessageStatusQueued, time.Now(), models.StatusSourceSendError)
+				session.Queue.Add(pendingMessage)
+				return
+			}
+
+			pendingMessage.Attempts++
+			if pendingMessage.Attempts >= app.Cfg.GetSendMaxAttempts() {
+				zap.S().Errorf("Error Sending Message: %s. Giving up after %d attempts", err.Error(), pendingMessage.Attempts)
+				app.MarkAsFailed(session, pendingMessage, models.StatusSourceSendError, err)
+				return
+			}
+
 			zap.S().Warnf("Error Sending Message: %s. Pushing message back to queue", err.Error())
-			app.Queue.Add(value.(PendingMessage))
+			session.Meow().UpdateMessageStatus(pendingMessage.MessageId, models.MessageStatusQueued, time.Now(), models.StatusSourceSendError)
+			session.Queue.Add(pendingMessage)
 			return
 		} else {
 			// mark as sent
-			app.MarkAsSent(e)
+			app.MarkAsSent(session, pendingMessage, resp.Timestamp)
 		}
 	}
 }
 
-func (app *Application) RemoveFromQueue(e *list.Element) {
-	app.Queue.Messages.Remove(e)
+// readyToSend checks the delivery window of the message, logging once when it starts being held.
+func (app *Application) readyToSend(session *Session, message PendingMessage, now time.Time) bool {
+	if app.inDeliveryWindow(message, now) {
+		session.held.Delete(message.MessageId)
+		return true
+	}
+
+	if _, alreadyHeld := session.held.LoadOrStore(message.MessageId, true); !alreadyHeld {
+		zap.S().Infof("Holding message %s to %s until its delivery window opens", message.MessageId, message.To)
+	}
+
+	return false
+}
+
+func (app *Application) MarkAsSending(session *Session, message PendingMessage) {
+	session.Meow().updateMessageStatus(message.MessageId, models.MessageStatusSending, time.Now(), models.StatusSourceQueue, "", map[string]interface{}{
+		"attempts": gorm.Expr("attempts + 1"),
+	})
 }
 
-func (app *Application) MarkAsSent(e *list.Element) {
+func (app *Application) MarkAsSent(session *Session, message PendingMessage, timestamp time.Time) {
 
 	zap.S().Debugf("Marking message as sent")
 
-	go func() {
-		app.MessageStore.
-			Model(&models.Message{}).
-			Where("message_id = ?", e.Value.(PendingMessage).MessageId).
-			Update("sent", true)
-	}()
+	go session.Meow().UpdateMessageStatus(message.MessageId, models.MessageStatusServerAcked, timestamp, models.StatusSourceSendResponse)
+}
+
+func (app *Application) MarkAsFailed(session *Session, message PendingMessage, source string, reason error) {
+	go app.failMessage(session, message.MessageId, source, reason)
+}
+
+func (app *Application) failMessage(session *Session, messageId string, source string, reason error) {
+	// a failed message must not be sent later on, e.g. once the recipient opted in again
+	session.Queue.Remove(func(message interface{}) bool {
+		return message.(PendingMessage).MessageId == messageId
+	})
+	session.held.Delete(messageId)
+	session.Meow().updateMessageStatus(messageId, models.MessageStatusFailed, time.Now(), source, "", map[string]interface{}{
+		"failure_reason": reason.Error(),
+	})
 }
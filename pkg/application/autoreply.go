@@ -0,0 +1,288 @@
+package application
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/jinzhu/gorm"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	"go.uber.org/zap"
+	"gomeow/cmd/models"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// AutoReplyTemplateData is available to the templates of text replies, e.g. "Hi {{.Name}}".
+type AutoReplyTemplateData struct {
+	Name  string
+	Phone string
+	Text  string
+	Date  string
+	Time  string
+}
+
+type autoReplyWebhookResponse struct {
+	Reply string `json:"reply"`
+}
+
+// AutoReply answers incoming messages with the first matching rule, by priority.
+type AutoReply struct {
+	db              *gorm.DB
+	queue           func(PendingMessage) (PendingMessage, error)
+	location        *time.Location
+	defaultCooldown time.Duration
+	client          *http.Client
+
+	mu          sync.Mutex
+	lastReplies map[string]time.Time
+	patterns    map[string]*regexp.Regexp
+}
+
+func NewAutoReply(db *gorm.DB, queue func(PendingMessage) (PendingMessage, error), location *time.Location, defaultCooldown time.Duration) *AutoReply {
+	return &AutoReply{
+		db:              db,
+		queue:           queue,
+		location:        location,
+		defaultCooldown: defaultCooldown,
+		client:          &http.Client{Timeout: 10 * time.Second},
+		lastReplies:     make(map[string]time.Time),
+		patterns:        make(map[string]*regexp.Regexp),
+	}
+}
+
+// Handle answers the message received by the session.
+func (a *AutoReply) Handle(session string, v *events.Message) {
+	if v.Info.IsFromMe || v.Info.Chat.Server == types.BroadcastServer {
+		return
+	}
+
+	incoming := parseIncomingMessage(v)
+	if incoming.Type == models.MessageTypeReaction {
+		return
+	}
+
+	var rules []models.AutoReplyRule
+	if err := a.db.Where("active = ?", true).Order("priority desc, id").Find(&rules).Error; err != nil {
+		zap.S().Errorf("Failed to load auto reply rules: %s", err)
+		return
+	}
+
+	text := strings.TrimSpace(incoming.Body)
+	now := time.Now().In(a.location)
+
+	for _, rule := range rules {
+		if !a.matches(rule, v, text, now) {
+			continue
+		}
+
+		// a rule cooling down still wins over the ones with a lower priority
+		if !a.acquireCooldown(rule, v.Info.Sender.User, now) {
+			zap.S().Debugf("Auto reply rule %d is cooling down for %s", rule.ID, v.Info.Sender.User)
+			return
+		}
+
+		zap.S().Debugf("Auto reply rule %d matched message %s", rule.ID, v.Info.ID)
+		if !a.reply(session, rule, v, incoming, now) {
+			// nothing was queued, so the cooldown doesn't start yet
+			a.releaseCooldown(rule, v.Info.Sender.User, now)
+		}
+		return
+	}
+}
+
+func (a *AutoReply) matches(rule models.AutoReplyRule, v *events.Message, text string, now time.Time) bool {
+	if len(rule.Group) == 0 {
+		if v.Info.IsGroup {
+			return false
+		}
+	} else if v.Info.Chat.String() != rule.Group && v.Info.Chat.User != rule.Group {
+		return false
+	}
+
+	if len(rule.Sender) > 0 {
+		sender := strings.TrimPrefix(rule.Sender, "+")
+		if v.Info.Sender.User != sender && v.Info.Sender.ToNonAD().String() != sender {
+			return false
+		}
+	}
+
+	if !withinWindow(rule.TimeFrom, rule.TimeTo, now) {
+		return false
+	}
+
+	switch rule.MatchType {
+	case models.AutoReplyMatchExact:
+		return strings.EqualFold(text, strings.TrimSpace(rule.Pattern))
+	case models.AutoReplyMatchContains:
+		return strings.Contains(strings.ToLower(text), strings.ToLower(rule.Pattern))
+	case models.AutoReplyMatchRegex:
+		pattern, err := a.compile(rule.Pattern)
+		if err != nil {
+			zap.S().Warnf("Auto reply rule %d has an invalid pattern: %s", rule.ID, err)
+			return false
+		}
+
+		return pattern.MatchString(text)
+	}
+
+	return false
+}
+
+func (a *AutoReply) compile(pattern string) (*regexp.Regexp, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if compiled, ok := a.patterns[pattern]; ok {
+		return compiled, nil
+	}
+
+	compiled, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	a.patterns[pattern] = compiled
+
+	return compiled, nil
+}
+
+// acquireCooldown records a reply of the rule to the sender,
+// unless the rule already replied to them within its cooldown.
+func (a *AutoReply) acquireCooldown(rule models.AutoReplyRule, sender string, now time.Time) bool {
+	cooldown := a.defaultCooldown
+	if rule.CooldownSeconds > 0 {
+		cooldown = time.Duration(rule.CooldownSeconds) * time.Second
+	}
+
+	key := fmt.Sprintf("%d|%s", rule.ID, sender)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if last, ok := a.lastReplies[key]; ok && now.Sub(last) < cooldown {
+		return false
+	}
+	a.lastReplies[key] = now
+
+	// forget expired cooldowns once in a while
+	if len(a.lastReplies) > 10000 {
+		for k, last := range a.lastReplies {
+			if now.Sub(last) > 24*time.Hour {
+				delete(a.lastReplies, k)
+			}
+		}
+	}
+
+	return true
+}
+
+// releaseCooldown forgets the reply recorded by acquireCooldown at the given time.
+func (a *AutoReply) releaseCooldown(rule models.AutoReplyRule, sender string, at time.Time) {
+	key := fmt.Sprintf("%d|%s", rule.ID, sender)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if last, ok := a.lastReplies[key]; ok && last.Equal(at) {
+		delete(a.lastReplies, key)
+	}
+}
+
+// reply queues the reply of the rule, and reports whether one was queued.
+func (a *AutoReply) reply(session string, rule models.AutoReplyRule, v *events.Message, incoming models.Message, now time.Time) bool {
+	// replies answer a message that just arrived, so delivery windows don't hold them
+	reply := PendingMessage{
+		Session: session,
+		To:      v.Info.Chat.ToNonAD().String(),
+		Urgent:  true,
+	}
+
+	switch rule.ReplyType {
+	case models.AutoReplyTypeText:
+		reply.Message = renderAutoReply(rule, v, incoming, now)
+
+	case models.AutoReplyTypeMedia:
+		reply.Type = MediaMessageType(rule.MimeType)
+		reply.MediaKey = rule.MediaKey
+		reply.MimeType = rule.MimeType
+		reply.FileName = rule.FileName
+		if len(rule.ReplyText) > 0 {
+			reply.Message = renderAutoReply(rule, v, incoming, now)
+		}
+
+	case models.AutoReplyTypeWebhook:
+		text, err := a.fetchReply(rule, v, incoming)
+		if err != nil {
+			zap.S().Errorf("Auto reply rule %d webhook failed: %s", rule.ID, err)
+			return false
+		}
+		if len(text) == 0 {
+			return false
+		}
+		reply.Message = text
+
+	default:
+		zap.S().Warnf("Auto reply rule %d has an unknown reply type %s", rule.ID, rule.ReplyType)
+		return false
+	}
+
+	if _, err := a.queue(reply); err != nil {
+		zap.S().Errorf("Failed to queue auto reply of rule %d: %s", rule.ID, err)
+		return false
+	}
+
+	return true
+}
+
+func renderAutoReply(rule models.AutoReplyRule, v *events.Message, incoming models.Message, now time.Time) string {
+	tmpl, err := template.New("reply").Parse(rule.ReplyText)
+	if err != nil {
+		zap.S().Warnf("Auto reply rule %d has an invalid template: %s", rule.ID, err)
+		return rule.ReplyText
+	}
+
+	var rendered bytes.Buffer
+	err = tmpl.Execute(&rendered, AutoReplyTemplateData{
+		Name:  v.Info.PushName,
+		Phone: v.Info.Sender.User,
+		Text:  incoming.Body,
+		Date:  now.Format("2006-01-02"),
+		Time:  now.Format("15:04"),
+	})
+	if err != nil {
+		zap.S().Warnf("Auto reply rule %d template failed: %s", rule.ID, err)
+		return rule.ReplyText
+	}
+
+	return rendered.String()
+}
+
+// fetchReply posts the incoming message to the webhook of the rule, which answers with {"reply": "..."}.
+func (a *AutoReply) fetchReply(rule models.AutoReplyRule, v *events.Message, incoming models.Message) (string, error) {
+	body, err := json.Marshal(newInboundMessagePayload(incoming, v.Info.IsGroup))
+	if err != nil {
+		return "", err
+	}
+
+	response, err := a.client.Post(rule.WebhookURL, "application/json; charset=UTF-8", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return "", fmt.Errorf("unexpected status code %d", response.StatusCode)
+	}
+
+	var reply autoReplyWebhookResponse
+	if err := json.NewDecoder(io.LimitReader(response.Body, 64*1024)).Decode(&reply); err != nil {
+		return "", err
+	}
+
+	return reply.Reply, nil
+}
@@ -0,0 +1,145 @@
+package application
+
+import (
+	"github.com/jinzhu/gorm"
+	"go.mau.fi/whatsmeow/appstate"
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"go.mau.fi/whatsmeow/types"
+	"go.uber.org/zap"
+	"gomeow/cmd/models"
+	"google.golang.org/protobuf/proto"
+	"time"
+)
+
+// MaxMarkRead is the most messages acknowledged at once.
+const MaxMarkRead = 1000
+
+// ChatState changes the state of a chat on every device of the session. Nil fields are left as they are.
+// Muting without a duration mutes the chat forever.
+type ChatState struct {
+	Archived     *bool `json:"archived"`
+	Pinned       *bool `json:"pinned"`
+	Muted        *bool `json:"muted"`
+	MuteDuration int   `json:"mute_duration"`
+	Unread       *bool `json:"unread"`
+}
+
+// MarkRead sends read receipts for the inbound messages of the chat, or for the ones after the last
+// acknowledged message when no message ids are given, which also clears the unread mark of the chat.
+// At most the latest MaxMarkRead of the messages are acknowledged.
+// It returns the ids of the acknowledged messages. Messages missing from the message store are skipped,
+// as the sender of group messages can't be known without them.
+func (app *Application) MarkRead(session *Session, chat types.JID, messageIds []string) ([]string, error) {
+	meow := session.Meow()
+	if !meow.Online() {
+		return nil, ErrNotConnected
+	}
+
+	jid := session.JID()
+	inbound := app.MessageStore.Model(&models.Message{}).
+		Where("jid = ? AND chat = ? AND direction = ?", jid, chat.String(), models.MessageDirectionInbound)
+
+	query := inbound
+	if len(messageIds) > 0 {
+		query = query.Where("message_id IN (?)", messageIds)
+	} else {
+		// messages before the last acknowledged one have been read by then,
+		// and of a long unread chat the latest messages are enough to read it up to its end
+		var last models.Message
+		if err := inbound.Where("receipt_sent_at IS NOT NULL").Select("id").Order("id desc").First(&last).Error; err != nil && !gorm.IsRecordNotFoundError(err) {
+			return nil, err
+		}
+		query = query.Where("id > ? AND receipt_sent_at IS NULL", last.ID)
+	}
+
+	var messages []models.Message
+	if err := query.Order("id desc").Limit(MaxMarkRead).Find(&messages).Error; err != nil {
+		return nil, err
+	}
+
+	// oldest first, like the chat
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+
+	// receipts are sent per sender, which only differs in groups
+	bySender := make(map[string][]types.MessageID)
+	var senders []string
+	for _, message := range messages {
+		if _, ok := bySender[message.Sender]; !ok {
+			senders = append(senders, message.Sender)
+		}
+		bySender[message.Sender] = append(bySender[message.Sender], message.MessageId)
+	}
+
+	now := time.Now()
+	acknowledged := []string{}
+	for _, sender := range senders {
+		senderJID, _ := types.ParseJID(sender)
+		if err := meow.Client.MarkRead(bySender[sender], now, chat, senderJID); err != nil {
+			return acknowledged, err
+		}
+
+		app.MessageStore.Model(&models.Message{}).
+			Where("jid = ? AND message_id IN (?)", jid, bySender[sender]).
+			UpdateColumn("receipt_sent_at", now)
+		acknowledged = append(acknowledged, bySender[sender]...)
+	}
+
+	if len(messageIds) == 0 {
+		if err := meow.Client.SendAppState(buildMarkChatAsRead(chat, true, now)); err != nil {
+			zap.S().Warnf("Failed to clear the unread mark of %s: %s", chat, err)
+		}
+	}
+
+	return acknowledged, nil
+}
+
+// UpdateChat archives, pins, mutes or marks the chat unread with app state patches, which sync to the phone.
+func (app *Application) UpdateChat(session *Session, chat types.JID, state ChatState) error {
+	meow := session.Meow()
+	if !meow.Online() {
+		return ErrNotConnected
+	}
+
+	var patches []appstate.PatchInfo
+	if state.Archived != nil {
+		patches = append(patches, appstate.BuildArchive(chat, *state.Archived, time.Time{}, nil))
+	}
+	if state.Pinned != nil {
+		patches = append(patches, appstate.BuildPin(chat, *state.Pinned))
+	}
+	if state.Muted != nil {
+		patches = append(patches, appstate.BuildMute(chat, *state.Muted, time.Duration(state.MuteDuration)*time.Second))
+	}
+	if state.Unread != nil {
+		patches = append(patches, buildMarkChatAsRead(chat, !*state.Unread, time.Now()))
+	}
+
+	for _, patch := range patches {
+		if err := meow.Client.SendAppState(patch); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// buildMarkChatAsRead builds the app state patch marking a chat read or unread, which appstate doesn't provide.
+func buildMarkChatAsRead(target types.JID, read bool, lastMessageTimestamp time.Time) appstate.PatchInfo {
+	return appstate.PatchInfo{
+		Type: appstate.WAPatchRegularLow,
+		Mutations: []appstate.MutationInfo{{
+			Index:   []string{appstate.IndexMarkChatAsRead, target.String()},
+			Version: 3,
+			Value: &waProto.SyncActionValue{
+				MarkChatAsReadAction: &waProto.MarkChatAsReadAction{
+					Read: proto.Bool(read),
+					MessageRange: &waProto.SyncActionMessageRange{
+						LastMessageTimestamp: proto.Int64(lastMessageTimestamp.Unix()),
+					},
+				},
+			},
+		}},
+	}
+}
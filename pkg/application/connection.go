@@ -0,0 +1,150 @@
+package application
+
+import (
+	"errors"
+	"fmt"
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types/events"
+	"go.uber.org/zap"
+	"time"
+)
+
+// Connection states.
+// disconnected -> connecting -> connected, then back to disconnected while reconnecting,
+// or stuck in logged_out / stream_replaced / temporary_ban / connect_failure until recovered.
+const (
+	ConnectionDisconnected   = "disconnected"
+	ConnectionConnecting     = "connecting"
+	ConnectionConnected      = "connected"
+	ConnectionLoggedOut      = "logged_out"
+	ConnectionStreamReplaced = "stream_replaced"
+	ConnectionTemporaryBan   = "temporary_ban"
+	ConnectionConnectFailure = "connect_failure"
+)
+
+// connectionAlerts are the states that need someone to look at the session, sent as "connection.<state>" webhooks.
+var connectionAlerts = map[string]string{
+	ConnectionLoggedOut:      "The session has been logged out and needs to be paired again.",
+	ConnectionStreamReplaced: "Another client connected with the credentials of the session. It won't reconnect on its own.",
+	ConnectionTemporaryBan:   "The number of the session has been temporarily banned.",
+	ConnectionConnectFailure: "WhatsApp refused the connection of the session.",
+}
+
+// connectFailureRetry is the delay before reconnecting after a connect failure whatsmeow doesn't recover from.
+const connectFailureRetry = time.Minute
+
+type ConnectionStatus struct {
+	State  string    `json:"state"`
+	Reason string    `json:"reason,omitempty"`
+	Since  time.Time `json:"since"`
+}
+
+func (m *Meow) Connection() ConnectionStatus {
+	m.connectionMu.Lock()
+	defer m.connectionMu.Unlock()
+
+	return m.connection
+}
+
+// Online checks whether messages can be sent right now.
+func (m *Meow) Online() bool {
+	return m.Connection().State == ConnectionConnected && m.Client.IsLoggedIn()
+}
+
+func (m *Meow) setConnection(state string, reason string) {
+	m.connectionMu.Lock()
+	changed := m.connection.State != state || m.connection.Reason != reason
+	if changed {
+		m.connection = ConnectionStatus{State: state, Reason: reason, Since: time.Now()}
+	}
+	m.connectionMu.Unlock()
+
+	if changed {
+		zap.S().Infof("Session %s is %s %s", m.Session, state, reason)
+		m.publish(StreamEventConnection, "", ConnectionPayload{State: state, Reason: reason})
+	}
+}
+
+// connect connects the client, tracking the connection state.
+func (m *Meow) connect() error {
+	m.setConnection(ConnectionConnecting, "")
+
+	err := m.Client.Connect()
+	if err != nil && !errors.Is(err, whatsmeow.ErrAlreadyConnected) {
+		m.setConnection(ConnectionDisconnected, err.Error())
+		return err
+	}
+
+	return nil
+}
+
+// handleConnectionEvent moves the connection state machine and schedules reconnects
+// the client doesn't do on its own. Plain disconnects are reconnected by whatsmeow.
+func (m *Meow) handleConnectionEvent(evt interface{}) {
+	switch v := evt.(type) {
+	case *events.Connected:
+		m.setConnection(ConnectionConnected, "")
+
+	case *events.Disconnected:
+		m.setConnection(ConnectionDisconnected, "")
+
+	case *events.LoggedOut:
+		m.setConnection(ConnectionLoggedOut, v.Reason.String())
+
+	case *events.StreamReplaced:
+		m.setConnection(ConnectionStreamReplaced, "")
+
+	case *events.TemporaryBan:
+		m.setConnection(ConnectionTemporaryBan, v.String())
+		if v.Expire > 0 {
+			time.AfterFunc(v.Expire, m.reconnectIf(ConnectionTemporaryBan))
+		}
+
+	case *events.ConnectFailure:
+		m.setConnection(ConnectionConnectFailure, fmt.Sprintf("%s %s", v.Reason, v.Message))
+		time.AfterFunc(connectFailureRetry, m.reconnectIf(ConnectionConnectFailure))
+	}
+}
+
+// reconnectIf returns a function reconnecting the client, unless it left the state in the meantime.
+func (m *Meow) reconnectIf(state string) func() {
+	return func() {
+		if m.Connection().State != state || m.Client.Store.ID == nil {
+			return
+		}
+
+		zap.S().Infof("Reconnecting session %s after %s", m.Session, state)
+		m.Client.Disconnect()
+		if err := m.connect(); err != nil {
+			zap.S().Errorf("Failed to reconnect session %s: %s", m.Session, err)
+		}
+	}
+}
+
+// alertConnection notifies webhooks and the alert email recipients about states needing attention.
+func (app *Application) alertConnection(session *Session, jid string, status ConnectionStatus) {
+	description, ok := connectionAlerts[status.State]
+	if !ok {
+		return
+	}
+
+	payload := struct {
+		Session string `json:"session"`
+		JID     string `json:"jid"`
+		ConnectionStatus
+	}{session.Name, jid, status}
+
+	if app.Webhooks != nil {
+		app.Webhooks.Dispatch("connection."+status.State, payload)
+	}
+
+	if app.Mailer != nil {
+		subject := fmt.Sprintf("[gomeow] Session %s: %s", session.Name, status.State)
+		body := fmt.Sprintf("%s\n\nSession: %s\nJID: %s\nState: %s\nReason: %s\nSince: %s\n",
+			description, session.Name, jid, status.State, status.Reason, status.Since.Format(time.RFC3339))
+
+		if err := app.Mailer.Send(subject, body); err != nil {
+			zap.S().Errorf("Failed to mail the %s alert of session %s: %s", status.State, session.Name, err)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package application
+
+import (
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/store"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	"gomeow/cmd/models"
+	"gomeow/pkg/config"
+	"gomeow/pkg/queues"
+	"gomeow/pkg/testdb"
+	"gomeow/pkg/webhook"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newTestMeow returns an unpaired client, which never connects.
+func newTestMeow() *Meow {
+	device := &store.Device{}
+
+	return &Meow{Session: "test", DeviceStore: device, Client: whatsmeow.NewClient(device, nil)}
+}
+
+func TestHandleConnectionEvent(t *testing.T) {
+	tests := []struct {
+		name   string
+		event  interface{}
+		state  string
+		reason string
+	}{
+		{"connected", &events.Connected{}, ConnectionConnected, ""},
+		{"disconnected", &events.Disconnected{}, ConnectionDisconnected, ""},
+		{"logged out", &events.LoggedOut{Reason: events.ConnectFailureLoggedOut}, ConnectionLoggedOut, events.ConnectFailureLoggedOut.String()},
+		{"stream replaced", &events.StreamReplaced{}, ConnectionStreamReplaced, ""},
+		{"temporary ban", &events.TemporaryBan{Code: events.TempBanSentToTooManyPeople}, ConnectionTemporaryBan, (&events.TemporaryBan{Code: events.TempBanSentToTooManyPeople}).String()},
+		{"connect failure", &events.ConnectFailure{Reason: events.ConnectFailureServiceUnavailable, Message: "later"}, ConnectionConnectFailure, events.ConnectFailureServiceUnavailable.String() + " later"},
+		{"other events leave the state", &events.Message{}, ConnectionConnecting, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			meow := newTestMeow()
+			meow.setConnection(ConnectionConnecting, "")
+
+			meow.handleConnectionEvent(test.event)
+
+			if connection := meow.Connection(); connection.State != test.state || connection.Reason != test.reason {
+				t.Errorf("expected %s %q, got %s %q", test.state, test.reason, connection.State, connection.Reason)
+			}
+		})
+	}
+}
+
+func TestHandleConnectionEventKeepsSince(t *testing.T) {
+	meow := newTestMeow()
+	meow.handleConnectionEvent(&events.Connected{})
+	since := meow.Connection().Since
+
+	meow.handleConnectionEvent(&events.Connected{})
+	if !meow.Connection().Since.Equal(since) {
+		t.Errorf("expected a repeated event to keep the state since %s, got %s", since, meow.Connection().Since)
+	}
+}
+
+func TestReconnectIf(t *testing.T) {
+	tests := []struct {
+		name   string
+		paired bool
+		state  string
+	}{
+		{"left the state in the meantime", true, ConnectionConnected},
+		{"device has been wiped", false, ConnectionConnectFailure},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			meow := newTestMeow()
+			if test.paired {
+				meow.Client.Store.ID = &types.JID{User: "628123456789", Server: types.DefaultUserServer}
+			}
+			meow.setConnection(test.state, "")
+			since := meow.Connection().Since
+
+			meow.reconnectIf(ConnectionConnectFailure)()
+
+			// a reconnect would have moved on to connecting
+			if connection := meow.Connection(); connection.State != test.state || !connection.Since.Equal(since) {
+				t.Errorf("expected to stay %s, got %s", test.state, connection.State)
+			}
+		})
+	}
+}
+
+func TestAlertConnection(t *testing.T) {
+	alerts := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		alerts <- r.Header.Get(webhook.HeaderEvent)
+	}))
+	defer server.Close()
+
+	db := testdb.Open(t, &models.Webhook{}, &models.WebhookDelivery{})
+	if err := db.Create(&models.Webhook{URL: server.URL, Secret: "secret", Active: true}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	app := &Application{Webhooks: webhook.NewDispatcher(db, 1, time.Second, time.Second)}
+	session := &Session{Name: "test"}
+
+	tests := []struct {
+		state string
+		alert bool
+	}{
+		{ConnectionConnected, false},
+		{ConnectionConnecting, false},
+		{ConnectionDisconnected, false},
+		{ConnectionLoggedOut, true},
+		{ConnectionStreamReplaced, true},
+		{ConnectionTemporaryBan, true},
+		{ConnectionConnectFailure, true},
+	}
+
+	for _, test := range tests {
+		app.alertConnection(session, "", ConnectionStatus{State: test.state, Since: time.Now()})
+
+		if !test.alert {
+			// states without an alert return before dispatching anything
+			select {
+			case event := <-alerts:
+				t.Errorf("%s: expected no alert, got %s", test.state, event)
+			default:
+			}
+			continue
+		}
+
+		select {
+		case event := <-alerts:
+			if event != "connection."+test.state {
+				t.Errorf("%s: expected the connection.%s alert, got %s", test.state, test.state, event)
+			}
+		case <-time.After(5 * time.Second):
+			t.Errorf("%s: expected an alert", test.state)
+		}
+	}
+}
+
+func TestSendMeowHoldsQueueWhileOffline(t *testing.T) {
+	app := &Application{Cfg: &config.Config{}}
+	session := &Session{Name: "test", Queue: queues.InitQueue()}
+	meow := newTestMeow()
+	session.meow.Store(meow)
+
+	session.Queue.Add(PendingMessage{MessageId: "a", To: "628123456789", Message: "hello"})
+
+	for _, state := range []string{ConnectionDisconnected, ConnectionConnecting, ConnectionLoggedOut, ConnectionTemporaryBan} {
+		meow.setConnection(state, "")
+		app.SendMeow(session)
+
+		if length := session.Queue.Len(); length != 1 {
+			t.Fatalf("%s: expected the message to stay queued, got a queue of %d", state, length)
+		}
+	}
+
+	message := session.Queue.Take(func(interface{}) bool { return true }).(PendingMessage)
+	if message.Attempts != 0 {
+		t.Errorf("expected no attempt to be used up, got %d", message.Attempts)
+	}
+}
@@ -0,0 +1,184 @@
+package application
+
+import (
+	"errors"
+	"go.mau.fi/whatsmeow/types"
+	"gomeow/pkg/phone"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MaxContactChecks is the most numbers checked with one lookup.
+const MaxContactChecks = 100
+
+var (
+	ErrNotConnected  = errors.New("session is not connected")
+	ErrNotOnWhatsApp = errors.New("recipient is not on WhatsApp")
+)
+
+// ContactCheck tells whether a number is registered on WhatsApp.
+type ContactCheck struct {
+	Phone        string    `json:"phone"`
+	Registered   bool      `json:"registered"`
+	JID          string    `json:"jid,omitempty"`
+	VerifiedName string    `json:"verified_name,omitempty"`
+	CheckedAt    time.Time `json:"checked_at"`
+	Error        string    `json:"error,omitempty"`
+}
+
+// maxCachedContacts bounds the contact cache, which bulk lookups of distinct numbers would grow without end.
+const maxCachedContacts = 50000
+
+// contactCache keeps lookups by normalized number. Registration doesn't depend on the session looking it up.
+type contactCache struct {
+	ttl        time.Duration
+	maxEntries int
+	mu         sync.Mutex
+	entries    map[string]ContactCheck
+}
+
+func newContactCache(ttl time.Duration) *contactCache {
+	return &contactCache{ttl: ttl, maxEntries: maxCachedContacts, entries: make(map[string]ContactCheck)}
+}
+
+func (c *contactCache) get(number string, now time.Time) (ContactCheck, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	check, ok := c.entries[number]
+	if !ok || now.Sub(check.CheckedAt) > c.ttl {
+		delete(c.entries, number)
+		return ContactCheck{}, false
+	}
+
+	return check, true
+}
+
+func (c *contactCache) put(check ContactCheck) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[check.Phone] = check
+	if len(c.entries) <= c.maxEntries {
+		return
+	}
+
+	// forget expired lookups once the cache is full, then arbitrary ones, making room for a tenth of it
+	for number, cached := range c.entries {
+		if check.CheckedAt.Sub(cached.CheckedAt) > c.ttl {
+			delete(c.entries, number)
+		}
+	}
+
+	for number := range c.entries {
+		if len(c.entries) <= c.maxEntries-c.maxEntries/10 {
+			break
+		}
+		if number != check.Phone {
+			delete(c.entries, number)
+		}
+	}
+}
+
+// CheckContacts looks up whether the numbers are registered on WhatsApp, using cached results while they are fresh.
+// Numbers that can't be normalized are returned with an error instead of failing the lookup.
+func (app *Application) CheckContacts(session *Session, numbers []string) ([]ContactCheck, error) {
+	now := time.Now()
+	checks := make([]ContactCheck, len(numbers))
+
+	var lookup []string
+	pending := make(map[string][]int)
+	for i, raw := range numbers {
+		number, err := app.Phone.Normalize(raw)
+		if err != nil {
+			checks[i] = ContactCheck{Phone: raw, CheckedAt: now, Error: phone.ErrInvalidPhone.Error()}
+			continue
+		}
+
+		if cached, ok := app.contacts.get(number, now); ok {
+			checks[i] = cached
+			continue
+		}
+
+		if _, ok := pending[number]; !ok {
+			lookup = append(lookup, "+"+number)
+		}
+		pending[number] = append(pending[number], i)
+	}
+
+	if len(lookup) > 0 {
+		if !session.Meow().Online() {
+			return nil, ErrNotConnected
+		}
+
+		responses, err := session.Meow().Client.IsOnWhatsApp(lookup)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, response := range responses {
+			number := strings.TrimPrefix(response.Query, "+")
+			if len(number) == 0 {
+				continue
+			}
+
+			check := ContactCheck{Phone: number, Registered: response.IsIn, CheckedAt: now}
+			if response.IsIn {
+				check.JID = response.JID.String()
+			}
+			if response.VerifiedName != nil && response.VerifiedName.Details != nil {
+				check.VerifiedName = response.VerifiedName.Details.GetVerifiedName()
+			}
+
+			app.contacts.put(check)
+			for _, i := range pending[number] {
+				checks[i] = check
+			}
+			delete(pending, number)
+		}
+
+		// numbers missing from the response aren't registered, but aren't cached as they may be missing for other reasons
+		for number, indexes := range pending {
+			for _, i := range indexes {
+				checks[i] = ContactCheck{Phone: number, CheckedAt: now}
+			}
+		}
+	}
+
+	return checks, nil
+}
+
+// checkRecipient returns the canonical JID of the recipient, or ErrNotOnWhatsApp.
+// Only users are checked, groups and other JIDs are returned as they are.
+func (app *Application) checkRecipient(session *Session, jid types.JID) (types.JID, error) {
+	if jid.Server != types.DefaultUserServer {
+		return jid, nil
+	}
+
+	checks, err := app.CheckContacts(session, []string{jid.User})
+	if err != nil {
+		return jid, err
+	}
+
+	// numbers that don't look like E.164 are left for WhatsApp to reject
+	if len(checks[0].Error) > 0 {
+		return jid, nil
+	}
+
+	if !checks[0].Registered {
+		return jid, ErrNotOnWhatsApp
+	}
+
+	return types.ParseJID(checks[0].JID)
+}
+
+func (app *Application) checkPendingRecipient(session *Session, message PendingMessage) error {
+	jid, err := recipientJID(message.To)
+	if err != nil {
+		return err
+	}
+
+	_, err = app.checkRecipient(session, jid)
+	return err
+}
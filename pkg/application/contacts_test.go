@@ -0,0 +1,53 @@
+package application
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestContactCacheExpires(t *testing.T) {
+	cache := newContactCache(time.Hour)
+	now := time.Now()
+	cache.put(ContactCheck{Phone: "628123456789", Registered: true, CheckedAt: now})
+
+	if check, ok := cache.get("628123456789", now.Add(time.Hour)); !ok || !check.Registered {
+		t.Errorf("expected the lookup to be cached for its ttl")
+	}
+
+	if _, ok := cache.get("628123456789", now.Add(time.Hour+time.Second)); ok {
+		t.Errorf("expected the lookup to expire")
+	}
+
+	if len(cache.entries) != 0 {
+		t.Errorf("expected the expired lookup to be forgotten, got %d entries", len(cache.entries))
+	}
+}
+
+func TestContactCacheIsBounded(t *testing.T) {
+	cache := newContactCache(time.Hour)
+	cache.maxEntries = 100
+	now := time.Now()
+
+	// expired lookups are forgotten first
+	for i := 0; i < 50; i++ {
+		cache.put(ContactCheck{Phone: "old" + strconv.Itoa(i), CheckedAt: now.Add(-2 * time.Hour)})
+	}
+	for i := 0; i < 51; i++ {
+		cache.put(ContactCheck{Phone: strconv.Itoa(i), CheckedAt: now})
+	}
+	if len(cache.entries) != 51 {
+		t.Fatalf("expected only the expired lookups to be forgotten, got %d entries", len(cache.entries))
+	}
+
+	for i := 51; i < 1000; i++ {
+		cache.put(ContactCheck{Phone: strconv.Itoa(i), CheckedAt: now})
+
+		if len(cache.entries) > cache.maxEntries {
+			t.Fatalf("expected at most %d entries, got %d", cache.maxEntries, len(cache.entries))
+		}
+		if _, ok := cache.get(strconv.Itoa(i), now); !ok {
+			t.Fatalf("expected the latest lookup %d to be kept", i)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package application
+
+import (
+	"errors"
+	"go.mau.fi/whatsmeow/types"
+	"go.uber.org/zap"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrInvalidDeliveryWindow = errors.New("delivery window needs both a start and an end, formatted as HH:MM")
+	ErrEmptyDeliveryWindow   = errors.New("delivery window start and end must differ")
+	ErrInvalidTimezone       = errors.New("unknown timezone")
+)
+
+// DefaultDeliveryCategory holds the window of messages without a category, or with one lacking its own window.
+const DefaultDeliveryCategory = "default"
+
+type DeliveryWindow struct {
+	Start string
+	End   string
+}
+
+// ParseDeliveryWindows parses "category=HH:MM-HH:MM" entries, skipping invalid ones.
+func ParseDeliveryWindows(entries []string) map[string]DeliveryWindow {
+	windows := make(map[string]DeliveryWindow)
+	for _, entry := range entries {
+		category, bounds, found := strings.Cut(entry, "=")
+		start, end, ok := strings.Cut(bounds, "-")
+		window := DeliveryWindow{Start: strings.TrimSpace(start), End: strings.TrimSpace(end)}
+		if !found || !ok || validateDeliveryWindow(window.Start, window.End) != nil {
+			zap.S().Warnf("Ignoring invalid delivery window %q", entry)
+			continue
+		}
+
+		windows[strings.ToLower(strings.TrimSpace(category))] = window
+	}
+
+	return windows
+}
+
+func validateDeliveryWindow(start string, end string) error {
+	if len(start) == 0 && len(end) == 0 {
+		return nil
+	}
+
+	startMinute, err := ParseClock(start)
+	if err != nil {
+		return ErrInvalidDeliveryWindow
+	}
+	endMinute, err := ParseClock(end)
+	if err != nil {
+		return ErrInvalidDeliveryWindow
+	}
+
+	// an empty window never opens, which would hold the message forever
+	if startMinute == endMinute {
+		return ErrEmptyDeliveryWindow
+	}
+
+	return nil
+}
+
+// validateDelivery checks the delivery window and timezone of a message before it is queued.
+func validateDelivery(message PendingMessage) error {
+	if err := validateDeliveryWindow(message.WindowStart, message.WindowEnd); err != nil {
+		return err
+	}
+
+	if len(message.Timezone) > 0 {
+		if loadLocation(message.Timezone) == nil {
+			return ErrInvalidTimezone
+		}
+	}
+
+	return nil
+}
+
+// deliveryWindow returns the window of the message: its own, the one of its category, or the default one.
+func (app *Application) deliveryWindow(message PendingMessage) (DeliveryWindow, bool) {
+	if len(message.WindowStart) > 0 {
+		return DeliveryWindow{Start: message.WindowStart, End: message.WindowEnd}, true
+	}
+
+	if window, ok := app.deliveryWindows[strings.ToLower(message.Category)]; ok && len(message.Category) > 0 {
+		return window, true
+	}
+
+	window, ok := app.deliveryWindows[DefaultDeliveryCategory]
+	return window, ok
+}
+
+// inDeliveryWindow checks whether the message may be sent now. Urgent messages are always sendable.
+func (app *Application) inDeliveryWindow(message PendingMessage, now time.Time) bool {
+	if message.Urgent {
+		return true
+	}
+
+	// messages stored before empty windows were rejected aren't held forever
+	window, ok := app.deliveryWindow(message)
+	if !ok || validateDeliveryWindow(window.Start, window.End) != nil {
+		return true
+	}
+
+	return withinWindow(window.Start, window.End, now.In(recipientLocation(message, app.location)))
+}
+
+// recipientLocation returns the explicit timezone of the message, or guesses it from
+// the country calling code of the recipient. Group chats use the fallback.
+func recipientLocation(message PendingMessage, fallback *time.Location) *time.Location {
+	if len(message.Timezone) > 0 {
+		if location := loadLocation(message.Timezone); location != nil {
+			return location
+		}
+	}
+
+	jid, err := recipientJID(message.To)
+	if err != nil || jid.Server != types.DefaultUserServer {
+		return fallback
+	}
+
+	// calling codes are prefix free, so at most one of them matches
+	for length := 1; length <= 3 && length <= len(jid.User); length++ {
+		if name, ok := countryTimezones[jid.User[:length]]; ok {
+			if location := loadLocation(name); location != nil {
+				return location
+			}
+		}
+	}
+
+	return fallback
+}
+
+// locations caches loaded timezones, since held messages are checked every second.
+var locations sync.Map
+
+// loadLocation returns the named timezone, or nil if it is unknown.
+func loadLocation(name string) *time.Location {
+	if location, ok := locations.Load(name); ok {
+		return location.(*time.Location)
+	}
+
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		location = nil
+	}
+	locations.Store(name, location)
+
+	return location
+}
+
+// countryTimezones maps country calling codes to the main timezone of the country.
+// Countries spanning several timezones use the one of their capital or largest population.
+var countryTimezones = map[string]string{
+	"1":   "America/New_York",
+	"7":   "Europe/Moscow",
+	"20":  "Africa/Cairo",
+	"27":  "Africa/Johannesburg",
+	"30":  "Europe/Athens",
+	"31":  "Europe/Amsterdam",
+	"32":  "Europe/Brussels",
+	"33":  "Europe/Paris",
+	"34":  "Europe/Madrid",
+	"36":  "Europe/Budapest",
+	"39":  "Europe/Rome",
+	"40":  "Europe/Bucharest",
+	"41":  "Europe/Zurich",
+	"43":  "Europe/Vienna",
+	"44":  "Europe/London",
+	"45":  "Europe/Copenhagen",
+	"46":  "Europe/Stockholm",
+	"47":  "Europe/Oslo",
+	"48":  "Europe/Warsaw",
+	"49":  "Europe/Berlin",
+	"51":  "America/Lima",
+	"52":  "America/Mexico_City",
+	"54":  "America/Argentina/Buenos_Aires",
+	"55":  "America/Sao_Paulo",
+	"56":  "America/Santiago",
+	"57":  "America/Bogota",
+	"60":  "Asia/Kuala_Lumpur",
+	"61":  "Australia/Sydney",
+	"62":  "Asia/Jakarta",
+	"63":  "Asia/Manila",
+	"64":  "Pacific/Auckland",
+	"65":  "Asia/Singapore",
+	"66":  "Asia/Bangkok",
+	"81":  "Asia/Tokyo",
+	"82":  "Asia/Seoul",
+	"84":  "Asia/Ho_Chi_Minh",
+	"86":  "Asia/Shanghai",
+	"90":  "Europe/Istanbul",
+	"91":  "Asia/Kolkata",
+	"92":  "Asia/Karachi",
+	"94":  "Asia/Colombo",
+	"95":  "Asia/Yangon",
+	"98":  "Asia/Tehran",
+	"212": "Africa/Casablanca",
+	"234": "Africa/Lagos",
+	"254": "Africa/Nairobi",
+	"351": "Europe/Lisbon",
+	"353": "Europe/Dublin",
+	"380": "Europe/Kiev",
+	"670": "Asia/Dili",
+	"673": "Asia/Brunei",
+	"852": "Asia/Hong_Kong",
+	"853": "Asia/Macau",
+	"855": "Asia/Phnom_Penh",
+	"856": "Asia/Vientiane",
+	"880": "Asia/Dhaka",
+	"886": "Asia/Taipei",
+	"966": "Asia/Riyadh",
+	"971": "Asia/Dubai",
+	"972": "Asia/Jerusalem",
+	"974": "Asia/Qatar",
+}
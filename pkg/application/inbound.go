@@ -0,0 +1,276 @@
+package application
+
+import (
+	"encoding/hex"
+	"fmt"
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	"go.uber.org/zap"
+	"gomeow/cmd/models"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"mime"
+	"path/filepath"
+	"time"
+)
+
+func (m *Meow) handleIncomingMessage(v *events.Message) {
+	// messages sent from our other devices and status broadcasts aren't inbound messages
+	if v.Info.IsFromMe || v.Info.Chat.Server == types.BroadcastServer {
+		return
+	}
+
+	// revokes, edits, ephemeral settings and sender key distributions aren't messages to store
+	if !hasContent(v) {
+		return
+	}
+
+	message := parseIncomingMessage(v)
+	message.JID = m.DeviceStore.ID.String()
+	message.Destination = m.DeviceStore.ID.User
+
+	// link replies and reactions to the message they refer to
+	if len(message.QuotedId) > 0 {
+		quoted := models.Message{}
+		if !m.DB.Select("id").Where("jid = ? AND message_id = ?", message.JID, message.QuotedId).First(&quoted).RecordNotFound() {
+			message.ReplyToID = &quoted.ID
+		}
+	}
+
+	// sessions in the same group, or chatting with each other, each store the message
+	if !m.DB.Select("id").Where("jid = ? AND message_id = ?", message.JID, message.MessageId).First(&models.Message{}).RecordNotFound() {
+		zap.S().Debugf("Incoming message %s has already been stored", message.MessageId)
+		return
+	}
+
+	if err := m.DB.Create(&message).Error; err != nil {
+		zap.S().Errorf("Failed to store incoming message %s: %s", message.MessageId, err)
+		return
+	}
+
+	m.RecordMessageEvent(message.MessageId, models.MessageStatusReceived, v.Info.Timestamp, models.StatusSourceMessage, message.Sender)
+
+	if m.DownloadMedia && downloadableTypes[message.Type] {
+		m.storeIncomingMedia(v, &message)
+	}
+
+	payload := newInboundMessagePayload(message, v.Info.IsGroup)
+	payload.Session = m.Session
+	m.publish(StreamEventMessage, message.Chat, payload)
+
+	if m.Webhooks != nil {
+		m.Webhooks.DispatchMessage("message.received", payload, v.Info.IsGroup, message.Body)
+	}
+}
+
+// hasContent checks whether the message carries anything besides protocol data, like
+// a revoke, an edit, an ephemeral setting, or a bare sender key distribution.
+func hasContent(v *events.Message) bool {
+	if v.Message == nil || v.IsEdit || v.Message.ProtocolMessage != nil {
+		return false
+	}
+
+	content := false
+	v.Message.ProtoReflect().Range(func(field protoreflect.FieldDescriptor, _ protoreflect.Value) bool {
+		switch field.Name() {
+		case "senderKeyDistributionMessage", "fastRatchetKeySenderKeyDistributionMessage", "messageContextInfo":
+			return true
+		}
+
+		content = true
+		return false
+	})
+
+	return content
+}
+
+var downloadableTypes = map[string]bool{
+	models.MessageTypeImage:    true,
+	models.MessageTypeVideo:    true,
+	models.MessageTypeAudio:    true,
+	models.MessageTypeDocument: true,
+	models.MessageTypeSticker:  true,
+}
+
+// storeIncomingMedia downloads and decrypts the media of the message, then puts it into the storage.
+// Media larger than the max size is skipped, as it would be held in memory completely.
+func (m *Meow) storeIncomingMedia(v *events.Message, message *models.Message) {
+	if m.MediaMaxSize > 0 && message.FileLength > m.MediaMaxSize {
+		zap.S().Infof("Not downloading media of message %s, %d bytes exceed the max size", message.MessageId, message.FileLength)
+		return
+	}
+
+	data, err := m.Client.DownloadAny(v.Message)
+	if err != nil {
+		zap.S().Errorf("Failed to download media of message %s: %s", message.MessageId, err)
+		return
+	}
+
+	key := mediaStorageKey(*message)
+	if err := m.Storage.Put(key, message.MimeType, data); err != nil {
+		zap.S().Errorf("Failed to store media of message %s: %s", message.MessageId, err)
+		return
+	}
+
+	message.StorageKey = key
+	m.DB.Model(message).UpdateColumn("storage_key", key)
+}
+
+func mediaStorageKey(message models.Message) string {
+	extension := filepath.Ext(message.FileName)
+	if len(extension) == 0 {
+		if extensions, _ := mime.ExtensionsByType(message.MimeType); len(extensions) > 0 {
+			extension = extensions[0]
+		}
+	}
+
+	return fmt.Sprintf("inbound/%s/%s%s", time.Now().Format("2006/01/02"), message.MessageId, extension)
+}
+
+type InboundMediaPayload struct {
+	MimeType   string `json:"mime_type"`
+	FileName   string `json:"file_name,omitempty"`
+	FileLength uint64 `json:"file_length"`
+	FileSha256 string `json:"file_sha256"`
+	StorageKey string `json:"storage_key,omitempty"`
+	Url        string `json:"url,omitempty"`
+}
+
+type InboundMessagePayload struct {
+	Session   string               `json:"session,omitempty"`
+	MessageId string               `json:"message_id"`
+	Sender    string               `json:"sender"`
+	Chat      string               `json:"chat"`
+	IsGroup   bool                 `json:"is_group"`
+	PushName  string               `json:"push_name"`
+	Type      string               `json:"type"`
+	Text      string               `json:"text"`
+	Media     *InboundMediaPayload `json:"media"`
+	QuotedId  string               `json:"quoted_id,omitempty"`
+	Timestamp *time.Time           `json:"timestamp"`
+}
+
+// newInboundMessagePayload normalizes a stored inbound message for webhooks.
+func newInboundMessagePayload(message models.Message, isGroup bool) InboundMessagePayload {
+	payload := InboundMessagePayload{
+		MessageId: message.MessageId,
+		Sender:    message.Sender,
+		Chat:      message.Chat,
+		IsGroup:   isGroup,
+		PushName:  message.PushName,
+		Type:      message.Type,
+		Text:      message.Body,
+		QuotedId:  message.QuotedId,
+		Timestamp: message.Timestamp,
+	}
+
+	if len(message.MimeType) > 0 {
+		payload.Media = &InboundMediaPayload{
+			MimeType:   message.MimeType,
+			FileName:   message.FileName,
+			FileLength: message.FileLength,
+			FileSha256: message.FileSha256,
+		}
+
+		if len(message.StorageKey) > 0 {
+			payload.Media.StorageKey = message.StorageKey
+			payload.Media.Url = "/api/v1/messages/" + message.MessageId + "/media"
+		}
+	}
+
+	return payload
+}
+
+// parseIncomingMessage maps the message content into the message store format.
+func parseIncomingMessage(v *events.Message) models.Message {
+	timestamp := v.Info.Timestamp
+	message := models.Message{
+		MessageId: v.Info.ID,
+		Direction: models.MessageDirectionInbound,
+		Status:    models.MessageStatusReceived,
+		Sender:    v.Info.Sender.ToNonAD().String(),
+		Chat:      v.Info.Chat.String(),
+		PushName:  v.Info.PushName,
+		Timestamp: &timestamp,
+	}
+
+	msg := v.Message
+	var contextInfo *waProto.ContextInfo
+
+	switch {
+	case len(msg.GetConversation()) > 0:
+		message.Type = models.MessageTypeText
+		message.Body = msg.GetConversation()
+
+	case msg.ExtendedTextMessage != nil:
+		message.Type = models.MessageTypeText
+		message.Body = msg.ExtendedTextMessage.GetText()
+		contextInfo = msg.ExtendedTextMessage.GetContextInfo()
+
+	case msg.ImageMessage != nil:
+		media := msg.ImageMessage
+		message.Type = models.MessageTypeImage
+		message.Body = media.GetCaption()
+		setMediaMetadata(&message, media.GetMimetype(), media.GetFileLength(), media.GetFileSHA256())
+		contextInfo = media.GetContextInfo()
+
+	case msg.VideoMessage != nil:
+		media := msg.VideoMessage
+		message.Type = models.MessageTypeVideo
+		message.Body = media.GetCaption()
+		setMediaMetadata(&message, media.GetMimetype(), media.GetFileLength(), media.GetFileSHA256())
+		contextInfo = media.GetContextInfo()
+
+	case msg.AudioMessage != nil:
+		media := msg.AudioMessage
+		message.Type = models.MessageTypeAudio
+		setMediaMetadata(&message, media.GetMimetype(), media.GetFileLength(), media.GetFileSHA256())
+		contextInfo = media.GetContextInfo()
+
+	case msg.DocumentMessage != nil:
+		media := msg.DocumentMessage
+		message.Type = models.MessageTypeDocument
+		message.Body = media.GetCaption()
+		message.FileName = media.GetFileName()
+		setMediaMetadata(&message, media.GetMimetype(), media.GetFileLength(), media.GetFileSHA256())
+		contextInfo = media.GetContextInfo()
+
+	case msg.StickerMessage != nil:
+		media := msg.StickerMessage
+		message.Type = models.MessageTypeSticker
+		setMediaMetadata(&message, media.GetMimetype(), media.GetFileLength(), media.GetFileSHA256())
+		contextInfo = media.GetContextInfo()
+
+	case msg.ReactionMessage != nil:
+		message.Type = models.MessageTypeReaction
+		message.Body = msg.ReactionMessage.GetText()
+		message.QuotedId = msg.ReactionMessage.GetKey().GetId()
+
+	case msg.LocationMessage != nil:
+		location := msg.LocationMessage
+		message.Type = models.MessageTypeLocation
+		message.Body = fmt.Sprintf("%f,%f %s", location.GetDegreesLatitude(), location.GetDegreesLongitude(), location.GetName())
+		contextInfo = location.GetContextInfo()
+
+	case msg.ContactMessage != nil:
+		message.Type = models.MessageTypeContact
+		message.Body = msg.ContactMessage.GetVcard()
+		message.FileName = msg.ContactMessage.GetDisplayName()
+		contextInfo = msg.ContactMessage.GetContextInfo()
+
+	default:
+		message.Type = models.MessageTypeUnknown
+	}
+
+	if contextInfo != nil {
+		message.QuotedId = contextInfo.GetStanzaId()
+	}
+
+	return message
+}
+
+func setMediaMetadata(message *models.Message, mimeType string, fileLength uint64, fileSha256 []byte) {
+	message.MimeType = mimeType
+	message.FileLength = fileLength
+	message.FileSha256 = hex.EncodeToString(fileSha256)
+}
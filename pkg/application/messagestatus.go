@@ -0,0 +1,248 @@
+package application
+
+import (
+	"github.com/jinzhu/gorm"
+	"go.mau.fi/whatsmeow/types/events"
+	"go.uber.org/zap"
+	"gomeow/cmd/models"
+	"time"
+)
+
+// statuses a message is allowed to move from, keyed by the target status
+var messageStatusSources = map[string][]string{
+	models.MessageStatusQueued:      {models.MessageStatusSending},
+	models.MessageStatusSending:     {models.MessageStatusQueued},
+	models.MessageStatusServerAcked: {models.MessageStatusSending},
+	models.MessageStatusDelivered:   {models.MessageStatusSending, models.MessageStatusServerAcked},
+	models.MessageStatusRead:        {models.MessageStatusSending, models.MessageStatusServerAcked, models.MessageStatusDelivered},
+	models.MessageStatusPlayed:      {models.MessageStatusSending, models.MessageStatusServerAcked, models.MessageStatusDelivered, models.MessageStatusRead},
+	models.MessageStatusFailed:      {models.MessageStatusQueued, models.MessageStatusSending},
+}
+
+var messageStatusTimestamps = map[string]string{
+	models.MessageStatusQueued:      "queued_at",
+	models.MessageStatusSending:     "sending_at",
+	models.MessageStatusServerAcked: "server_acked_at",
+	models.MessageStatusDelivered:   "delivered_at",
+	models.MessageStatusRead:        "read_at",
+	models.MessageStatusPlayed:      "played_at",
+	models.MessageStatusFailed:      "failed_at",
+}
+
+// webhook events dispatched when a message reaches a status
+var messageStatusEvents = map[string]string{
+	models.MessageStatusServerAcked: "message.sent",
+	models.MessageStatusDelivered:   "message.delivered",
+	models.MessageStatusRead:        "message.read",
+	models.MessageStatusPlayed:      "message.played",
+	models.MessageStatusFailed:      "message.failed",
+}
+
+// receipt statuses, ordered from the least to the most advanced
+var receiptStatusRank = map[string]int{
+	models.MessageStatusDelivered: 1,
+	models.MessageStatusRead:      2,
+	models.MessageStatusPlayed:    3,
+}
+
+// UpdateMessageStatus moves a message to the given status when the transition is allowed,
+// and records when it happened. It returns true if the status has been changed.
+func (m *Meow) UpdateMessageStatus(messageId string, status string, at time.Time, source string) bool {
+	return m.updateMessageStatus(messageId, status, at, source, "", nil)
+}
+
+// RecordMessageEvent appends an entry to the status history of a message.
+func (m *Meow) RecordMessageEvent(messageId string, status string, at time.Time, source string, participant string) {
+	m.recordMessageEvent(messageId, status, at, source, participant, false)
+}
+
+func (m *Meow) recordMessageEvent(messageId string, status string, at time.Time, source string, participant string, ignored bool) {
+	event := models.MessageEvent{
+		MessageId:   messageId,
+		Status:      status,
+		Source:      source,
+		Participant: participant,
+		Ignored:     ignored,
+		OccurredAt:  at,
+	}
+
+	if err := m.DB.Create(&event).Error; err != nil {
+		zap.S().Errorf("Failed to record %s event of message %s: %s", status, messageId, err)
+	}
+}
+
+func (m *Meow) updateMessageStatus(messageId string, status string, at time.Time, source string, participant string, fields map[string]interface{}) bool {
+	column := messageStatusTimestamps[status]
+
+	values := map[string]interface{}{
+		"status": status,
+		column:   at,
+	}
+	for key, value := range fields {
+		values[key] = value
+	}
+
+	// the statuses are those of outbound messages, the recipient may be another session storing the same id
+	result := m.DB.Model(&models.Message{}).
+		Where("message_id = ? AND direction = ? AND status IN (?)", messageId, models.MessageDirectionOutbound, messageStatusSources[status]).
+		Updates(values)
+
+	if result.Error != nil {
+		zap.S().Errorf("Failed to update status of message %s to %s: %s", messageId, status, result.Error)
+		return false
+	}
+
+	// rejected transitions stay in the timeline, marked as not having changed the status
+	m.recordMessageEvent(messageId, status, at, source, participant, result.RowsAffected == 0)
+
+	if result.RowsAffected == 0 {
+		// acks and receipts may arrive out of order, keep the first time each one has been seen
+		if _, isReceipt := receiptStatusRank[status]; isReceipt || status == models.MessageStatusServerAcked {
+			m.DB.Model(&models.Message{}).
+				Where("message_id = ? AND direction = ? AND "+column+" IS NULL", messageId, models.MessageDirectionOutbound).
+				UpdateColumn(column, at)
+		}
+
+		return false
+	}
+
+	zap.S().Debugf("Message %s is now %s", messageId, status)
+
+	m.notifyStatusChange(messageId, status, at, participant)
+
+	return true
+}
+
+type MessageStatusPayload struct {
+	MessageId     string    `json:"message_id"`
+	JID           string    `json:"jid"`
+	Destination   string    `json:"destination"`
+	Status        string    `json:"status"`
+	Participant   string    `json:"participant,omitempty"`
+	FailureReason string    `json:"failure_reason,omitempty"`
+	Timestamp     time.Time `json:"timestamp"`
+}
+
+func (m *Meow) notifyStatusChange(messageId string, status string, at time.Time, participant string) {
+	message := models.Message{}
+	if err := m.DB.Where("message_id = ? AND direction = ?", messageId, models.MessageDirectionOutbound).First(&message).Error; err != nil {
+		zap.S().Errorf("Failed to load message %s for notifications: %s", messageId, err)
+		return
+	}
+
+	payload := MessageStatusPayload{
+		MessageId:     message.MessageId,
+		JID:           message.JID,
+		Destination:   message.Destination,
+		Status:        status,
+		Participant:   participant,
+		FailureReason: message.FailureReason,
+		Timestamp:     at,
+	}
+
+	m.publish(StreamEventStatus, message.Chat, payload)
+
+	if event, ok := messageStatusEvents[status]; ok && m.Webhooks != nil {
+		m.Webhooks.Dispatch(event, payload)
+	}
+}
+
+// updateRecipientStatus tracks receipts of a single group participant.
+func (m *Meow) updateRecipientStatus(messageId string, participant string, status string, at time.Time) {
+	recipient := models.MessageRecipient{}
+	err := m.DB.
+		Where(models.MessageRecipient{MessageId: messageId, Participant: participant}).
+		Attrs(models.MessageRecipient{Status: status}).
+		FirstOrCreate(&recipient).Error
+	if err != nil {
+		zap.S().Errorf("Failed to store receipt of %s for message %s: %s", participant, messageId, err)
+		return
+	}
+
+	column := messageStatusTimestamps[status]
+	m.DB.Model(&recipient).Where(column+" IS NULL").UpdateColumn(column, at)
+
+	if receiptStatusRank[status] > receiptStatusRank[recipient.Status] {
+		m.DB.Model(&recipient).Update("status", status)
+	}
+}
+
+func (m *Meow) handleReceipt(v *events.Receipt) {
+	var status string
+
+	switch v.Type {
+	case events.ReceiptTypeDelivered:
+		status = models.MessageStatusDelivered
+	case events.ReceiptTypeRead:
+		status = models.MessageStatusRead
+	case events.ReceiptTypePlayed:
+		status = models.MessageStatusPlayed
+	case events.ReceiptTypeRetry:
+		zap.S().Warnf("%s failed to decrypt messages %s, waiting for a retry", v.Sender, v.MessageIDs)
+		return
+	default:
+		// sender and read-self receipts only concern our own devices
+		zap.S().Debugf("Received a %s receipt from own device [%s]", v.Type, v.MessageIDs)
+		return
+	}
+
+	// receipts sent by our own devices are about incoming messages
+	if v.IsFromMe {
+		return
+	}
+
+	zap.S().Debugf("Received a %s receipt from %s [%s]", status, v.Sender, v.MessageIDs)
+
+	participant := v.Sender.ToNonAD().String()
+	for _, messageId := range v.MessageIDs {
+		if v.IsGroup {
+			m.updateRecipientStatus(messageId, participant, status, v.Timestamp)
+		}
+
+		m.updateMessageStatus(messageId, status, v.Timestamp, models.StatusSourceReceipt, participant, nil)
+	}
+}
+
+// migrateLegacyMessageFlags converts the sent and read flags used by
+// older versions into message statuses, then drops the old columns.
+// migrateMessageIdIndex drops the unique index of message ids from before several sessions,
+// which the unique index of jid and message id replaces.
+func migrateMessageIdIndex(db *gorm.DB) {
+	message := &models.Message{}
+	if !db.Dialect().HasIndex(message.TableName(), "message_id") {
+		return
+	}
+
+	zap.S().Info("Scoping unique message ids to their session")
+	if err := db.Model(message).RemoveIndex("message_id").Error; err != nil {
+		zap.S().Errorf("Failed to drop the unique index of message ids: %s", err)
+	}
+}
+
+func migrateLegacyMessageFlags(db *gorm.DB) {
+	message := &models.Message{}
+	if !db.Dialect().HasColumn(message.TableName(), "sent") {
+		return
+	}
+
+	zap.S().Info("Migrating legacy message flags to statuses")
+
+	db.Model(message).
+		Where("sent = ? AND status = ?", true, models.MessageStatusQueued).
+		UpdateColumns(map[string]interface{}{
+			"status":          models.MessageStatusServerAcked,
+			"server_acked_at": gorm.Expr("updated_at"),
+		})
+
+	if db.Dialect().HasColumn(message.TableName(), "read") {
+		db.Model(message).
+			Where("`read` = ?", true).
+			UpdateColumns(map[string]interface{}{
+				"status":  models.MessageStatusRead,
+				"read_at": gorm.Expr("updated_at"),
+			})
+		db.Model(message).DropColumn("read")
+	}
+
+	db.Model(message).DropColumn("sent")
+}
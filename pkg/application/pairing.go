@@ -0,0 +1,185 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/mdp/qrterminal/v3"
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types/events"
+	"go.uber.org/zap"
+	"os"
+	"strings"
+	"time"
+)
+
+// Pairing statuses.
+// unpaired -> waiting -> scanned -> success, or timeout / error
+const (
+	PairingStatusUnpaired = "unpaired"
+	PairingStatusWaiting  = "waiting"
+	PairingStatusScanned  = "scanned"
+	PairingStatusSuccess  = "success"
+	PairingStatusTimeout  = "timeout"
+	PairingStatusError    = "error"
+)
+
+var (
+	ErrAlreadyPaired = errors.New("device is already paired")
+	ErrNotPaired     = errors.New("device is not paired yet")
+)
+
+// PairingState is the progress of linking this device to a WhatsApp account.
+type PairingState struct {
+	Status    string     `json:"status"`
+	Code      string     `json:"code,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	PairCode  string     `json:"pair_code,omitempty"`
+	JID       string     `json:"jid,omitempty"`
+	Error     string     `json:"error,omitempty"`
+}
+
+func (m *Meow) Pairing() PairingState {
+	m.pairingMu.Lock()
+	defer m.pairingMu.Unlock()
+
+	return m.pairing
+}
+
+// LastConnectedAt returns when the client connected the last time, if it ever did.
+func (m *Meow) LastConnectedAt() *time.Time {
+	m.pairingMu.Lock()
+	defer m.pairingMu.Unlock()
+
+	return m.connectedAt
+}
+
+func (m *Meow) updatePairing(update func(state *PairingState)) {
+	m.pairingMu.Lock()
+	defer m.pairingMu.Unlock()
+
+	update(&m.pairing)
+}
+
+// StartPairing connects without credentials and starts emitting QR codes.
+// It does nothing while codes are still being emitted.
+func (m *Meow) StartPairing() error {
+	m.pairingStart.Lock()
+	defer m.pairingStart.Unlock()
+
+	if m.Client.Store.ID != nil {
+		return ErrAlreadyPaired
+	}
+
+	if m.Client.IsConnected() && m.Pairing().Status == PairingStatusWaiting {
+		return nil
+	}
+
+	// the login websocket of a timed out pairing may still be open
+	m.Client.Disconnect()
+
+	qrChan, err := m.Client.GetQRChannel(context.Background())
+	if err != nil {
+		return err
+	}
+
+	m.updatePairing(func(state *PairingState) {
+		*state = PairingState{Status: PairingStatusWaiting}
+	})
+
+	zap.S().Info("Connecting to WhatsApp")
+	if err := m.connect(); err != nil {
+		return err
+	}
+
+	go m.watchQRChannel(qrChan)
+
+	return nil
+}
+
+// PairPhone requests a pairing code for the phone number, to be entered
+// on the phone under "Link with phone number instead".
+func (m *Meow) PairPhone(phone string) (string, error) {
+	if err := m.StartPairing(); err != nil {
+		return "", err
+	}
+
+	code, err := m.Client.PairPhone(strings.TrimPrefix(phone, "+"), true, whatsmeow.PairClientChrome, "Chrome (Linux)")
+	if err != nil {
+		return "", err
+	}
+
+	m.updatePairing(func(state *PairingState) {
+		state.PairCode = code
+	})
+
+	return code, nil
+}
+
+func (m *Meow) watchQRChannel(qrChan <-chan whatsmeow.QRChannelItem) {
+	for evt := range qrChan {
+		m.publish(StreamEventQR, "", QRPayload{Event: evt.Event, Code: evt.Code})
+
+		switch evt.Event {
+		case whatsmeow.QRChannelEventCode:
+			expiresAt := time.Now().Add(evt.Timeout)
+			m.updatePairing(func(state *PairingState) {
+				state.Status = PairingStatusWaiting
+				state.Code = evt.Code
+				state.ExpiresAt = &expiresAt
+			})
+
+			// Render the QR code here
+			// e.g. qrterminal.GenerateHalfBlock(evt.Code, qrterminal.L, os.Stdout)
+			// or just manually `echo 2@... | qrencode -t ansiutf8` in a terminal
+			qrterminal.GenerateHalfBlock(evt.Code, qrterminal.L, os.Stdout)
+			fmt.Println("QR code:", evt.Code)
+
+		case whatsmeow.QRChannelSuccess.Event:
+			m.updatePairing(func(state *PairingState) {
+				// the connected event may have been handled already
+				if state.Status != PairingStatusSuccess {
+					state.Status = PairingStatusScanned
+				}
+			})
+
+		case whatsmeow.QRChannelTimeout.Event:
+			m.updatePairing(func(state *PairingState) {
+				*state = PairingState{Status: PairingStatusTimeout}
+			})
+
+		default:
+			reason := evt.Event
+			if evt.Error != nil {
+				reason = evt.Error.Error()
+			}
+			m.updatePairing(func(state *PairingState) {
+				*state = PairingState{Status: PairingStatusError, Error: reason}
+			})
+		}
+
+		zap.S().Infof("Pairing event: %s", evt.Event)
+	}
+}
+
+func (m *Meow) pairingEventHandler(evt interface{}) {
+	switch v := evt.(type) {
+	case *events.PairSuccess:
+		m.updatePairing(func(state *PairingState) {
+			state.Status = PairingStatusScanned
+			state.JID = v.ID.String()
+		})
+
+	case *events.PairError:
+		m.updatePairing(func(state *PairingState) {
+			*state = PairingState{Status: PairingStatusError, JID: v.ID.String(), Error: v.Error.Error()}
+		})
+
+	case *events.Connected:
+		connectedAt := time.Now()
+		m.updatePairing(func(state *PairingState) {
+			*state = PairingState{Status: PairingStatusSuccess, JID: m.Client.Store.ID.String()}
+			m.connectedAt = &connectedAt
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package application
+
+import (
+	"errors"
+	"go.mau.fi/whatsmeow/types"
+	"go.uber.org/zap"
+	"gomeow/cmd/models"
+	"math"
+	"time"
+	"unicode/utf8"
+)
+
+// Chat presence states of the presence endpoint. Recording is composing an audio message.
+const (
+	ChatPresenceComposing = "composing"
+	ChatPresenceRecording = "recording"
+	ChatPresencePaused    = "paused"
+)
+
+// minTypingDelay keeps short messages from being sent the moment typing started.
+const minTypingDelay = time.Second
+
+var ErrInvalidPresence = errors.New("invalid presence")
+
+// Humanizer makes sends look typed: the chat shows the session composing
+// for a time based on the length of the message before it is sent.
+type Humanizer struct {
+	typingSpeed   float64
+	maxDelay      time.Duration
+	presenceAfter string
+}
+
+// NewHumanizer returns a humanizer typing the characters per second, for at most maxDelay.
+// The presence after sending is available, unavailable, or empty to leave it as it is.
+// Only with a presence after sending is the session marked available while typing,
+// as staying available stops the phone from getting push notifications.
+func NewHumanizer(typingSpeed int, maxDelay time.Duration, presenceAfter string) (*Humanizer, error) {
+	switch types.Presence(presenceAfter) {
+	case "", types.PresenceAvailable, types.PresenceUnavailable:
+	default:
+		return nil, ErrInvalidPresence
+	}
+
+	if typingSpeed <= 0 {
+		typingSpeed = 1
+	}
+
+	return &Humanizer{typingSpeed: float64(typingSpeed), maxDelay: maxDelay, presenceAfter: presenceAfter}, nil
+}
+
+// typingDelay is how long typing the message takes, between minTypingDelay and the max delay.
+func (h *Humanizer) typingDelay(message PendingMessage) time.Duration {
+	delay := time.Duration(float64(utf8.RuneCountInString(message.Message)) / h.typingSpeed * float64(time.Second))
+
+	return time.Duration(math.Min(math.Max(float64(delay), float64(minTypingDelay)), float64(h.maxDelay)))
+}
+
+// beforeSend shows the session as composing, or recording for audio, in the chat and waits for the typing delay.
+// Presence can't be sent without a push name, failures are logged and don't stop the message.
+func (h *Humanizer) beforeSend(meow *Meow, message PendingMessage) {
+	jid, err := recipientJID(message.To)
+	if err != nil {
+		return
+	}
+
+	// others only see chat presence while the session is available,
+	// so it is only marked available when afterSend changes the presence again
+	if len(h.presenceAfter) > 0 {
+		if err := meow.Client.SendPresence(types.PresenceAvailable); err != nil {
+			zap.S().Warnf("Failed to mark session %s available: %s", meow.Session, err)
+		}
+	}
+
+	state := ChatPresenceComposing
+	if message.Type == models.MessageTypeAudio {
+		state = ChatPresenceRecording
+	}
+	if err := meow.SetChatPresence(jid, state); err != nil {
+		zap.S().Warnf("Failed to mark session %s %s in %s: %s", meow.Session, state, jid, err)
+	}
+
+	time.Sleep(h.typingDelay(message))
+
+	if err := meow.SetChatPresence(jid, ChatPresencePaused); err != nil {
+		zap.S().Warnf("Failed to mark session %s paused in %s: %s", meow.Session, jid, err)
+	}
+}
+
+func (h *Humanizer) afterSend(meow *Meow) {
+	if len(h.presenceAfter) == 0 {
+		return
+	}
+
+	if err := meow.SetPresence(h.presenceAfter); err != nil {
+		zap.S().Warnf("Failed to mark session %s %s: %s", meow.Session, h.presenceAfter, err)
+	}
+}
+
+// SetPresence marks the session available or unavailable to all contacts.
+func (m *Meow) SetPresence(presence string) error {
+	switch types.Presence(presence) {
+	case types.PresenceAvailable, types.PresenceUnavailable:
+		return m.Client.SendPresence(types.Presence(presence))
+	default:
+		return ErrInvalidPresence
+	}
+}
+
+// SetChatPresence shows the session composing, recording or paused in the chat.
+func (m *Meow) SetChatPresence(jid types.JID, state string) error {
+	switch state {
+	case ChatPresenceComposing:
+		return m.Client.SendChatPresence(jid, types.ChatPresenceComposing, types.ChatPresenceMediaText)
+	case ChatPresenceRecording:
+		return m.Client.SendChatPresence(jid, types.ChatPresenceComposing, types.ChatPresenceMediaAudio)
+	case ChatPresencePaused:
+		return m.Client.SendChatPresence(jid, types.ChatPresencePaused, types.ChatPresenceMediaText)
+	default:
+		return ErrInvalidPresence
+	}
+}
@@ -0,0 +1,128 @@
+package application
+
+import (
+	"errors"
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types"
+	"go.uber.org/zap"
+	"sort"
+	"strings"
+)
+
+// ContactInfo are the names of a user the session knows from its contact store.
+type ContactInfo struct {
+	JID          string `json:"jid"`
+	FirstName    string `json:"first_name,omitempty"`
+	FullName     string `json:"full_name,omitempty"`
+	PushName     string `json:"push_name,omitempty"`
+	BusinessName string `json:"business_name,omitempty"`
+}
+
+// Profile is what WhatsApp tells about a user, along with the names from the contact store.
+type Profile struct {
+	ContactInfo
+	Registered   bool     `json:"registered"`
+	VerifiedName string   `json:"verified_name,omitempty"`
+	About        string   `json:"about,omitempty"`
+	PictureID    string   `json:"picture_id,omitempty"`
+	PictureURL   string   `json:"picture_url,omitempty"`
+	Devices      []string `json:"devices"`
+}
+
+// RecipientJID parses a JID, or normalizes a phone number into the JID of a user.
+func (app *Application) RecipientJID(to string) (types.JID, error) {
+	if !strings.Contains(to, "@") {
+		number, err := app.Phone.Normalize(to)
+		if err != nil {
+			return types.JID{}, err
+		}
+		to = number
+	}
+
+	return recipientJID(to)
+}
+
+// Contacts lists the users in the contact store of the session, by JID.
+func (app *Application) Contacts(session *Session) ([]ContactInfo, error) {
+	contacts, err := session.Meow().Client.Store.Contacts.GetAllContacts()
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]ContactInfo, 0, len(contacts))
+	for jid, contact := range contacts {
+		infos = append(infos, contactInfo(jid, contact))
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].JID < infos[j].JID
+	})
+
+	return infos, nil
+}
+
+// Profile looks up the about text, verified business name, devices and profile picture of the user.
+// A hidden or missing profile picture leaves the picture empty.
+func (app *Application) Profile(session *Session, jid types.JID) (Profile, error) {
+	meow := session.Meow()
+	if !meow.Online() {
+		return Profile{}, ErrNotConnected
+	}
+
+	contact, err := meow.Client.Store.Contacts.GetContact(jid)
+	if err != nil {
+		return Profile{}, err
+	}
+
+	profile := Profile{ContactInfo: contactInfo(jid, contact), Devices: []string{}}
+
+	users, err := meow.Client.GetUserInfo([]types.JID{jid})
+	if err != nil {
+		return Profile{}, err
+	}
+
+	user, ok := users[jid]
+	if !ok {
+		return profile, nil
+	}
+
+	profile.Registered = true
+	profile.About = user.Status
+	profile.PictureID = user.PictureID
+	if user.VerifiedName != nil && user.VerifiedName.Details != nil {
+		profile.VerifiedName = user.VerifiedName.Details.GetVerifiedName()
+	}
+	for _, device := range user.Devices {
+		profile.Devices = append(profile.Devices, device.String())
+	}
+
+	if len(user.PictureID) > 0 {
+		picture, err := app.ProfilePicture(session, jid, false)
+		if err == nil && picture != nil {
+			profile.PictureURL = picture.URL
+		} else if err != nil && !errors.Is(err, whatsmeow.ErrProfilePictureNotSet) && !errors.Is(err, whatsmeow.ErrProfilePictureUnauthorized) {
+			zap.S().Warnf("Failed to get the profile picture of %s: %s", jid, err)
+		}
+	}
+
+	return profile, nil
+}
+
+// ProfilePicture returns where the full size picture, or its preview, can be downloaded.
+func (app *Application) ProfilePicture(session *Session, jid types.JID, preview bool) (*types.ProfilePictureInfo, error) {
+	meow := session.Meow()
+	if !meow.Online() {
+		return nil, ErrNotConnected
+	}
+
+	return meow.Client.GetProfilePictureInfo(jid, &whatsmeow.GetProfilePictureParams{Preview: preview})
+}
+
+func contactInfo(jid types.JID, contact types.ContactInfo) ContactInfo {
+	return ContactInfo{
+		JID:          jid.String(),
+		FirstName:    contact.FirstName,
+		FullName:     contact.FullName,
+		PushName:     contact.PushName,
+		BusinessName: contact.BusinessName,
+	}
+}
@@ -0,0 +1,142 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"go.mau.fi/whatsmeow"
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"go.mau.fi/whatsmeow/types"
+	"gomeow/cmd/models"
+	"google.golang.org/protobuf/proto"
+	"io"
+	"net/http"
+	"strings"
+)
+
+var uploadMediaTypes = map[string]whatsmeow.MediaType{
+	models.MessageTypeImage:    whatsmeow.MediaImage,
+	models.MessageTypeVideo:    whatsmeow.MediaVideo,
+	models.MessageTypeAudio:    whatsmeow.MediaAudio,
+	models.MessageTypeDocument: whatsmeow.MediaDocument,
+}
+
+// MediaMessageType picks the message type used to send a file of the given MIME type.
+func MediaMessageType(mimeType string) string {
+	switch {
+	case strings.HasPrefix(mimeType, "image/"):
+		return models.MessageTypeImage
+	case strings.HasPrefix(mimeType, "video/"):
+		return models.MessageTypeVideo
+	case strings.HasPrefix(mimeType, "audio/"):
+		return models.MessageTypeAudio
+	default:
+		return models.MessageTypeDocument
+	}
+}
+
+// recipientJID accepts either a full JID, or a phone number of a user.
+func recipientJID(to string) (types.JID, error) {
+	if strings.Contains(to, "@") {
+		return types.ParseJID(to)
+	}
+
+	return types.NewJID(to, types.DefaultUserServer), nil
+}
+
+// buildMessage creates the WhatsApp message of a pending message,
+// uploading its media from the storage when needed.
+func (m *Meow) buildMessage(message PendingMessage) (*waProto.Message, error) {
+	if len(message.Type) == 0 || message.Type == models.MessageTypeText {
+		return &waProto.Message{
+			ExtendedTextMessage: &waProto.ExtendedTextMessage{
+				Text: proto.String(message.Message),
+			},
+		}, nil
+	}
+
+	mediaType, ok := uploadMediaTypes[message.Type]
+	if !ok {
+		return nil, fmt.Errorf("unsupported message type %s", message.Type)
+	}
+
+	file, err := m.Storage.Get(message.MediaKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read media %s: %w", message.MediaKey, err)
+	}
+	data, err := io.ReadAll(file)
+	file.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read media %s: %w", message.MediaKey, err)
+	}
+
+	mimeType := message.MimeType
+	if len(mimeType) == 0 {
+		mimeType = http.DetectContentType(data)
+	}
+
+	uploaded, err := m.Client.Upload(context.Background(), data, mediaType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload media %s: %w", message.MediaKey, err)
+	}
+
+	var caption *string
+	if len(message.Message) > 0 {
+		caption = proto.String(message.Message)
+	}
+
+	switch message.Type {
+	case models.MessageTypeImage:
+		return &waProto.Message{ImageMessage: &waProto.ImageMessage{
+			Caption:       caption,
+			Mimetype:      proto.String(mimeType),
+			URL:           proto.String(uploaded.URL),
+			DirectPath:    proto.String(uploaded.DirectPath),
+			MediaKey:      uploaded.MediaKey,
+			FileEncSHA256: uploaded.FileEncSHA256,
+			FileSHA256:    uploaded.FileSHA256,
+			FileLength:    proto.Uint64(uploaded.FileLength),
+		}}, nil
+
+	case models.MessageTypeVideo:
+		return &waProto.Message{VideoMessage: &waProto.VideoMessage{
+			Caption:       caption,
+			Mimetype:      proto.String(mimeType),
+			URL:           proto.String(uploaded.URL),
+			DirectPath:    proto.String(uploaded.DirectPath),
+			MediaKey:      uploaded.MediaKey,
+			FileEncSHA256: uploaded.FileEncSHA256,
+			FileSHA256:    uploaded.FileSHA256,
+			FileLength:    proto.Uint64(uploaded.FileLength),
+		}}, nil
+
+	case models.MessageTypeAudio:
+		return &waProto.Message{AudioMessage: &waProto.AudioMessage{
+			Mimetype:      proto.String(mimeType),
+			URL:           proto.String(uploaded.URL),
+			DirectPath:    proto.String(uploaded.DirectPath),
+			MediaKey:      uploaded.MediaKey,
+			FileEncSHA256: uploaded.FileEncSHA256,
+			FileSHA256:    uploaded.FileSHA256,
+			FileLength:    proto.Uint64(uploaded.FileLength),
+		}}, nil
+
+	default:
+		fileName := message.FileName
+		if len(fileName) == 0 {
+			fileName = "document"
+		}
+
+		return &waProto.Message{DocumentMessage: &waProto.DocumentMessage{
+			Caption:       caption,
+			Title:         proto.String(fileName),
+			FileName:      proto.String(fileName),
+			Mimetype:      proto.String(mimeType),
+			URL:           proto.String(uploaded.URL),
+			DirectPath:    proto.String(uploaded.DirectPath),
+			MediaKey:      uploaded.MediaKey,
+			FileEncSHA256: uploaded.FileEncSHA256,
+			FileSHA256:    uploaded.FileSHA256,
+			FileLength:    proto.Uint64(uploaded.FileLength),
+		}}, nil
+	}
+}
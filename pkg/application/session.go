@@ -0,0 +1,373 @@
+package application
+
+import (
+	"errors"
+	"go.mau.fi/whatsmeow/store"
+	"go.uber.org/zap"
+	"gomeow/cmd/models"
+	"gomeow/pkg/queues"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultSessionName is the name of the first session, used when a request doesn't choose one.
+const DefaultSessionName = "default"
+
+var (
+	ErrSessionNotFound    = errors.New("session not found")
+	ErrSessionExists      = errors.New("session already exists")
+	ErrInvalidSessionName = errors.New("session names may only contain letters, digits, dashes and underscores")
+	ErrLastSession        = errors.New("the last session can't be deleted")
+	ErrSessionReset       = errors.New("the device of the session was unlinked before the message was sent")
+)
+
+var sessionNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// Session is a WhatsApp number served by the gateway, with its own client and queue.
+type Session struct {
+	Name  string
+	Queue *queues.Queue
+
+	meow        atomic.Pointer[Meow]
+	held        sync.Map
+	queueLoaded atomic.Bool
+	sending     sync.Mutex
+}
+
+// Meow returns the client of the session. It is replaced when the device is wiped.
+func (s *Session) Meow() *Meow {
+	return s.meow.Load()
+}
+
+// JID returns the JID of the paired device, or an empty string.
+func (s *Session) JID() string {
+	if s.Meow().DeviceStore.ID == nil {
+		return ""
+	}
+
+	return s.Meow().DeviceStore.ID.String()
+}
+
+type SessionInfo struct {
+	Name            string     `json:"name"`
+	JID             string     `json:"jid"`
+	PushName        string     `json:"push_name"`
+	BusinessName    string     `json:"business_name,omitempty"`
+	Platform        string     `json:"platform"`
+	Status          string     `json:"status"`
+	Connection      string     `json:"connection"`
+	Connected       bool       `json:"connected"`
+	LoggedIn        bool       `json:"logged_in"`
+	LastConnectedAt *time.Time `json:"last_connected_at"`
+	Queued          int        `json:"queued"`
+}
+
+func (s *Session) Info() SessionInfo {
+	meow := s.Meow()
+
+	return SessionInfo{
+		Name:            s.Name,
+		JID:             s.JID(),
+		PushName:        meow.DeviceStore.PushName,
+		BusinessName:    meow.DeviceStore.BusinessName,
+		Platform:        meow.DeviceStore.Platform,
+		Status:          meow.Pairing().Status,
+		Connection:      meow.Connection().State,
+		Connected:       meow.Client.IsConnected(),
+		LoggedIn:        meow.Client.IsLoggedIn(),
+		LastConnectedAt: meow.LastConnectedAt(),
+		Queued:          s.Queue.Len(),
+	}
+}
+
+// SessionRegistry keeps the sessions in the order they have been created.
+// The first one is the default session.
+type SessionRegistry struct {
+	mu       sync.RWMutex
+	sessions []*Session
+}
+
+func NewSessionRegistry() *SessionRegistry {
+	return &SessionRegistry{}
+}
+
+func (r *SessionRegistry) Add(session *Session) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, s := range r.sessions {
+		if s.Name == session.Name {
+			return ErrSessionExists
+		}
+	}
+	r.sessions = append(r.sessions, session)
+
+	return nil
+}
+
+func (r *SessionRegistry) Remove(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, s := range r.sessions {
+		if s.Name == name {
+			r.sessions = append(r.sessions[:i:i], r.sessions[i+1:]...)
+			return
+		}
+	}
+}
+
+// Get finds a session by its name, its JID or the phone number of its JID.
+// An empty key returns the default session.
+func (r *SessionRegistry) Get(key string) (*Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if len(r.sessions) == 0 {
+		return nil, ErrSessionNotFound
+	}
+
+	if len(key) == 0 {
+		return r.sessions[0], nil
+	}
+
+	for _, s := range r.sessions {
+		if s.Name == key {
+			return s, nil
+		}
+	}
+
+	for _, s := range r.sessions {
+		if id := s.Meow().DeviceStore.ID; id != nil && (id.String() == key || id.ToNonAD().String() == key || id.User == key) {
+			return s, nil
+		}
+	}
+
+	return nil, ErrSessionNotFound
+}
+
+func (r *SessionRegistry) All() []*Session {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return append([]*Session(nil), r.sessions...)
+}
+
+// loadSessions creates a session for every stored session and device.
+// Devices paired before sessions existed get a session of their own, the first one named "default".
+func (app *Application) loadSessions() {
+	devices, err := app.DB.GetAllDevices()
+	if err != nil {
+		zap.S().Panicf("Failed to load devices: %s", err)
+		panic(err)
+	}
+
+	var rows []models.Session
+	app.MessageStore.Order("id").Find(&rows)
+
+	for _, row := range rows {
+		// sessions without a stored device, e.g. never paired ones, pair again
+		device := app.DB.NewDevice()
+		for _, stored := range devices {
+			if stored.ID != nil && stored.ID.String() == row.JID {
+				device = stored
+			}
+		}
+
+		session := app.newSessionWithDevice(row.Name, device)
+		if err := app.Sessions.Add(session); err != nil {
+			zap.S().Errorf("Failed to load session %s: %s", row.Name, err)
+		}
+	}
+
+	for _, device := range devices {
+		if _, err := app.Sessions.Get(device.ID.String()); err == nil {
+			continue
+		}
+
+		name := DefaultSessionName
+		if _, err := app.Sessions.Get(name); err == nil {
+			name = device.ID.User
+		}
+
+		row := models.Session{Name: name, JID: device.ID.String()}
+		if err := app.MessageStore.Create(&row).Error; err != nil {
+			zap.S().Errorf("Failed to store session %s: %s", name, err)
+		}
+		_ = app.Sessions.Add(app.newSessionWithDevice(name, device))
+	}
+
+	if len(app.Sessions.All()) == 0 {
+		if _, err := app.CreateSession(DefaultSessionName); err != nil {
+			zap.S().Panicf("Failed to create the default session: %s", err)
+			panic(err)
+		}
+	}
+}
+
+// CreateSession adds a new, unpaired session. Pairing is started once it is connected.
+func (app *Application) CreateSession(name string) (*Session, error) {
+	if !sessionNamePattern.MatchString(name) {
+		return nil, ErrInvalidSessionName
+	}
+
+	if _, err := app.Sessions.Get(name); err == nil {
+		return nil, ErrSessionExists
+	}
+
+	if err := app.MessageStore.Create(&models.Session{Name: name}).Error; err != nil {
+		return nil, err
+	}
+
+	session := app.newSessionWithDevice(name, app.DB.NewDevice())
+	if err := app.Sessions.Add(session); err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+func (app *Application) newSessionWithDevice(name string, device *store.Device) *Session {
+	session := &Session{
+		Name:  name,
+		Queue: queues.InitQueue(),
+	}
+	app.useDevice(session, device)
+
+	return session
+}
+
+// useDevice gives the session a new client for the device.
+func (app *Application) useDevice(session *Session, device *store.Device) {
+	meow := Init(app.Cfg, session.Name, device, app.MessageStore, app.Webhooks, app.Storage, app.Stream)
+	meow.Events.Subscribe(app.eventHandler(session, meow))
+	session.meow.Store(meow)
+}
+
+// LogoutSession unlinks the device from the phone and deletes it from the device store.
+// The session gets a new device, which can be paired again.
+func (app *Application) LogoutSession(session *Session) error {
+	meow := session.Meow()
+	if !meow.Client.IsLoggedIn() {
+		return ErrNotPaired
+	}
+
+	if err := meow.Client.Logout(); err != nil {
+		return err
+	}
+
+	app.resetSession(session)
+
+	return nil
+}
+
+// ReconnectSession drops the connection of the session and connects again.
+// Unpaired sessions start pairing instead.
+func (app *Application) ReconnectSession(session *Session) error {
+	meow := session.Meow()
+	if meow.Client.Store.ID == nil {
+		return meow.StartPairing()
+	}
+
+	meow.Client.Disconnect()
+
+	return meow.connect()
+}
+
+// WipeSession deletes the device of the session from the device store, even when it can't be unlinked,
+// e.g. because the phone already unlinked it. The session gets a new device, which can be paired again.
+func (app *Application) WipeSession(session *Session) error {
+	meow := session.Meow()
+	if meow.Client.IsLoggedIn() {
+		if err := meow.Client.Logout(); err != nil {
+			zap.S().Warnf("Failed to unlink the device of session %s, deleting it anyway: %s", session.Name, err)
+		}
+	}
+
+	meow.Client.Disconnect()
+	if meow.Client.Store.ID != nil {
+		if err := meow.Client.Store.Delete(); err != nil {
+			return err
+		}
+	}
+
+	app.resetSession(session)
+
+	return nil
+}
+
+// DeleteSession wipes the device of the session and forgets the session.
+// The last session can't be deleted.
+func (app *Application) DeleteSession(session *Session) error {
+	if len(app.Sessions.All()) == 1 {
+		return ErrLastSession
+	}
+
+	if err := app.WipeSession(session); err != nil {
+		return err
+	}
+	session.Meow().Exit()
+
+	app.Sessions.Remove(session.Name)
+
+	return app.MessageStore.Where("name = ?", session.Name).Delete(&models.Session{}).Error
+}
+
+func (app *Application) resetSession(session *Session) {
+	zap.S().Infof("Session %s needs to be paired again", session.Name)
+
+	// the device may already be deleted, the session row still knows its JID
+	var row models.Session
+	app.MessageStore.Where("name = ?", session.Name).First(&row)
+
+	// the messages of the old number must not be sent by whichever number pairs next
+	session.Queue.Drain()
+	session.held.Range(func(key, _ interface{}) bool {
+		session.held.Delete(key)
+		return true
+	})
+	app.failUnsent(session, row.JID)
+
+	previous := session.Meow()
+	app.useDevice(session, app.DB.NewDevice())
+	app.MessageStore.Model(&models.Session{}).Where("name = ?", session.Name).Update("jid", "")
+
+	// the reset may run on the event loop of the old client, so it is disconnected in the background
+	go previous.Exit()
+
+	// the next device loads its own queue once it is paired
+	session.queueLoaded.Store(false)
+}
+
+// failUnsent fails the queued and sending messages of the JID.
+func (app *Application) failUnsent(session *Session, jid string) {
+	if len(jid) == 0 {
+		return
+	}
+
+	var messages []models.Message
+	app.MessageStore.
+		Select("message_id").
+		Where("direction = ? AND status IN (?) AND jid = ?", models.MessageDirectionOutbound, []string{models.MessageStatusQueued, models.MessageStatusSending}, jid).
+		Find(&messages)
+
+	for _, message := range messages {
+		app.failMessage(session, message.MessageId, models.StatusSourceSession, ErrSessionReset)
+	}
+
+	if len(messages) > 0 {
+		zap.S().Infof("Failed %d unsent messages of session %s", len(messages), session.Name)
+	}
+}
+
+// session returns the session of the JID, falling back to the default session.
+func (app *Application) session(jid string) *Session {
+	if session, err := app.Sessions.Get(jid); err == nil {
+		return session
+	}
+
+	session, _ := app.Sessions.Get("")
+	return session
+}
@@ -0,0 +1,102 @@
+package application
+
+import (
+	"go.mau.fi/whatsmeow/types/events"
+	"time"
+)
+
+// Stream event types
+const (
+	StreamEventMessage      = "message.received"
+	StreamEventStatus       = "message.status"
+	StreamEventReceipt      = "receipt"
+	StreamEventConnection   = "connection"
+	StreamEventQR           = "qr"
+	StreamEventPresence     = "presence"
+	StreamEventChatPresence = "chat_presence"
+)
+
+// PairingStreamEvents carry the codes to pair a session with,
+// so they are only streamed to keys allowed to pair sessions.
+var PairingStreamEvents = []string{StreamEventQR}
+
+type ReceiptPayload struct {
+	Type       string    `json:"type"`
+	Chat       string    `json:"chat"`
+	Sender     string    `json:"sender"`
+	IsGroup    bool      `json:"is_group"`
+	IsFromMe   bool      `json:"is_from_me"`
+	MessageIds []string  `json:"message_ids"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+type ConnectionPayload struct {
+	State  string `json:"state"`
+	Reason string `json:"reason,omitempty"`
+}
+
+type QRPayload struct {
+	Event string `json:"event"`
+	Code  string `json:"code,omitempty"`
+}
+
+type PresencePayload struct {
+	From        string     `json:"from"`
+	Unavailable bool       `json:"unavailable"`
+	LastSeen    *time.Time `json:"last_seen"`
+}
+
+type ChatPresencePayload struct {
+	Chat   string `json:"chat"`
+	Sender string `json:"sender"`
+	State  string `json:"state"`
+	Media  string `json:"media"`
+}
+
+func (m *Meow) publish(eventType string, chat string, data interface{}) {
+	if m.Stream != nil {
+		m.Stream.Publish(m.Session, eventType, chat, data)
+	}
+}
+
+// publishEvent forwards whatsmeow events to the event stream.
+// Incoming messages are published once they have been stored,
+// connection events once they moved the connection state.
+func (m *Meow) publishEvent(evt interface{}) {
+	switch v := evt.(type) {
+	case *events.Receipt:
+		receiptType := string(v.Type)
+		if v.Type == events.ReceiptTypeDelivered {
+			receiptType = "delivered"
+		}
+
+		m.publish(StreamEventReceipt, v.Chat.String(), ReceiptPayload{
+			Type:       receiptType,
+			Chat:       v.Chat.String(),
+			Sender:     v.Sender.ToNonAD().String(),
+			IsGroup:    v.IsGroup,
+			IsFromMe:   v.IsFromMe,
+			MessageIds: v.MessageIDs,
+			Timestamp:  v.Timestamp,
+		})
+
+	case *events.Presence:
+		payload := PresencePayload{
+			From:        v.From.String(),
+			Unavailable: v.Unavailable,
+		}
+		if !v.LastSeen.IsZero() {
+			payload.LastSeen = &v.LastSeen
+		}
+
+		m.publish(StreamEventPresence, v.From.String(), payload)
+
+	case *events.ChatPresence:
+		m.publish(StreamEventChatPresence, v.Chat.String(), ChatPresencePayload{
+			Chat:   v.Chat.String(),
+			Sender: v.Sender.ToNonAD().String(),
+			State:  string(v.State),
+			Media:  string(v.Media),
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package application
+
+import (
+	"errors"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	"go.uber.org/zap"
+	"gomeow/cmd/models"
+	"strings"
+)
+
+var ErrRecipientSuppressed = errors.New("recipient has opted out of receiving messages")
+
+// IsSuppressed checks whether the recipient, a phone number or a JID, has opted out.
+// Group chats are never suppressed.
+func (app *Application) IsSuppressed(to string) bool {
+	jid, err := recipientJID(to)
+	if err != nil || jid.Server != types.DefaultUserServer {
+		return false
+	}
+
+	return !app.MessageStore.Select("id").Where("phone = ?", jid.User).First(&models.Suppression{}).RecordNotFound()
+}
+
+// Suppress adds the phone number to the suppression list.
+func (app *Application) Suppress(phone string, source string, reason string, messageId string) (models.Suppression, error) {
+	suppression := models.Suppression{}
+	err := app.MessageStore.
+		Where(models.Suppression{Phone: phone}).
+		Attrs(models.Suppression{Source: source, Reason: reason, MessageId: messageId}).
+		FirstOrCreate(&suppression).Error
+
+	return suppression, err
+}
+
+// Unsuppress removes the phone number from the suppression list.
+func (app *Application) Unsuppress(phone string) error {
+	return app.MessageStore.Where("phone = ?", phone).Delete(&models.Suppression{}).Error
+}
+
+// handleOptOut processes opt-out and opt-in keywords sent in direct chats.
+// It returns true when the message was one of the keywords.
+func (app *Application) handleOptOut(v *events.Message) bool {
+	if v.Info.IsFromMe || v.Info.IsGroup || v.Info.Chat.Server != types.DefaultUserServer {
+		return false
+	}
+
+	text := strings.TrimSpace(parseIncomingMessage(v).Body)
+	if len(text) == 0 {
+		return false
+	}
+
+	phone := v.Info.Sender.User
+
+	if matchesKeyword(app.Cfg.GetOptOutKeywords(), text) {
+		zap.S().Infof("%s opted out with %q", phone, text)
+		if _, err := app.Suppress(phone, models.SuppressionSourceKeyword, text, v.Info.ID); err != nil {
+			zap.S().Errorf("Failed to suppress %s: %s", phone, err)
+		}
+		app.failQueuedMessages(phone)
+
+		return true
+	}
+
+	if matchesKeyword(app.Cfg.GetOptInKeywords(), text) {
+		zap.S().Infof("%s opted in with %q", phone, text)
+		if err := app.Unsuppress(phone); err != nil {
+			zap.S().Errorf("Failed to unsuppress %s: %s", phone, err)
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// failQueuedMessages marks messages still waiting for the phone number as failed,
+// and drops them from the queue.
+func (app *Application) failQueuedMessages(phone string) {
+	var messages []models.Message
+	app.MessageStore.
+		Where("direction = ? AND status = ? AND chat = ?", models.MessageDirectionOutbound, models.MessageStatusQueued, types.NewJID(phone, types.DefaultUserServer).String()).
+		Find(&messages)
+
+	for _, message := range messages {
+		app.failMessage(app.session(message.JID), message.MessageId, models.StatusSourceSuppression, ErrRecipientSuppressed)
+	}
+}
+
+func matchesKeyword(keywords []string, text string) bool {
+	for _, keyword := range keywords {
+		if strings.EqualFold(text, keyword) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,41 @@
+package application
+
+import (
+	"fmt"
+	"time"
+)
+
+// ParseClock parses a "HH:MM" time of day into minutes after midnight.
+func ParseClock(clock string) (int, error) {
+	parsed, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", clock)
+	}
+
+	return parsed.Hour()*60 + parsed.Minute(), nil
+}
+
+// withinWindow checks whether the time of day of now is between from (inclusive)
+// and to (exclusive). Windows may wrap around midnight, e.g. 21:00-07:00.
+// An empty or invalid bound means the window is always open.
+func withinWindow(from string, to string, now time.Time) bool {
+	if len(from) == 0 || len(to) == 0 {
+		return true
+	}
+
+	start, err := ParseClock(from)
+	if err != nil {
+		return true
+	}
+	end, err := ParseClock(to)
+	if err != nil {
+		return true
+	}
+
+	current := now.Hour()*60 + now.Minute()
+	if start <= end {
+		return current >= start && current < end
+	}
+
+	return current >= start || current < end
+}
@@ -4,33 +4,61 @@ import (
 	"context"
 	"fmt"
 	"github.com/jinzhu/gorm"
-	"github.com/mdp/qrterminal/v3"
 	"go.mau.fi/whatsmeow"
 	waProto "go.mau.fi/whatsmeow/binary/proto"
 	"go.mau.fi/whatsmeow/store"
-	"go.mau.fi/whatsmeow/store/sqlstore"
-	"go.mau.fi/whatsmeow/types"
 	"go.mau.fi/whatsmeow/types/events"
 	waLog "go.mau.fi/whatsmeow/util/log"
 	"go.uber.org/zap"
-	"gomeow/cmd/models"
 	"gomeow/pkg/config"
-	"google.golang.org/protobuf/proto"
-	"os"
+	"gomeow/pkg/dispatcher"
+	"gomeow/pkg/storage"
+	"gomeow/pkg/stream"
+	"gomeow/pkg/webhook"
 	"strings"
+	"sync"
+	"time"
 )
 
 type Meow struct {
+	Session     string
 	DeviceStore *store.Device
 	ClientLog   waLog.Logger
 	Client      *whatsmeow.Client
 	DB          *gorm.DB
+	Webhooks    *webhook.Dispatcher
+	Storage     storage.Storage
+	Stream      *stream.Hub
+	Events      *dispatcher.Dispatcher
+
+	// download media of incoming messages into the storage, up to the max size in bytes
+	DownloadMedia bool
+	MediaMaxSize  uint64
+
+	pairingMu    sync.Mutex
+	pairingStart sync.Mutex
+	pairing      PairingState
+	connectedAt  *time.Time
+
+	connectionMu sync.Mutex
+	connection   ConnectionStatus
 }
 
 type PendingMessage struct {
-	Message   string `json:"message"`
-	To        string `json:"to"`
-	MessageId string `json:"messageId"`
+	Session     string `json:"session,omitempty"`
+	Message     string `json:"message"`
+	To          string `json:"to"`
+	MessageId   string `json:"messageId"`
+	Type        string `json:"type,omitempty"`
+	MediaKey    string `json:"mediaKey,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+	FileName    string `json:"fileName,omitempty"`
+	Category    string `json:"category,omitempty"`
+	WindowStart string `json:"windowStart,omitempty"`
+	WindowEnd   string `json:"windowEnd,omitempty"`
+	Timezone    string `json:"timezone,omitempty"`
+	Urgent      bool   `json:"urgent,omitempty"`
+	Attempts    int    `json:"-"`
 }
 
 type CustomLogger waLog.Logger
@@ -83,24 +111,12 @@ func InitZapLogger(module string, minLevel string) waLog.Logger {
 	}
 }
 
-func Init(c *config.Config, container *sqlstore.Container, db *gorm.DB) *Meow {
+func Init(c *config.Config, session string, deviceStore *store.Device, db *gorm.DB, webhooks *webhook.Dispatcher, mediaStorage storage.Storage, hub *stream.Hub) *Meow {
 	// init device store
 	store.DeviceProps.PlatformType = waProto.DeviceProps_CHROME.Enum()
 	//store.CompanionProps.Os = waProto.UserAgent_WINDOWS.String()
 	//store.CompanionProps.Version = "1.0.0"
-	zap.S().Info("Initializing DeviceStore")
-	deviceStore, err := container.GetFirstDevice()
-	if err != nil {
-		zap.S().Panicf("Error: %s", err)
-		panic(err)
-	}
-
-	zap.S().Debug("JID: ", deviceStore.ID)
-	//zap.S().Fatal("Exited")
-
-	//fmt.Printf("%+v\n", store.CompanionProps)
-	//fmt.Printf("%+v\n", deviceStore.Platform)
-	//panic("test")
+	zap.S().Debugf("Session %s JID: %v", session, deviceStore.ID)
 
 	// init client log
 	logLevel := "ERROR"
@@ -111,49 +127,56 @@ func Init(c *config.Config, container *sqlstore.Container, db *gorm.DB) *Meow {
 	clientLog := InitZapLogger("Client", logLevel)
 
 	// init client
-	zap.S().Info("Initializing WhatsMeow Client")
+	zap.S().Infof("Initializing WhatsMeow Client of session %s", session)
 	client := whatsmeow.NewClient(deviceStore, clientLog)
 
-	return &Meow{
+	meow := &Meow{
+		Session:     session,
 		DeviceStore: deviceStore,
 		ClientLog:   clientLog,
 		Client:      client,
 		DB:          db,
+		Webhooks:    webhooks,
+		Storage:     mediaStorage,
+		Stream:      hub,
+		Events:      dispatcher.New(),
+
+		DownloadMedia: c.GetMediaDownload(),
+		MediaMaxSize:  c.GetMediaMaxSize(),
 	}
+
+	meow.connection = ConnectionStatus{State: ConnectionDisconnected, Since: time.Now()}
+	meow.pairing = PairingState{Status: PairingStatusUnpaired}
+	if deviceStore.ID != nil {
+		meow.pairing = PairingState{Status: PairingStatusSuccess, JID: deviceStore.ID.String()}
+	}
+
+	// the one and only handler of the client, modules subscribe to meow.Events
+	client.AddEventHandler(meow.Events.Dispatch)
+	meow.Events.Subscribe(meow.pairingEventHandler)
+	meow.Events.Subscribe(meow.eventHandler)
+
+	return meow
 }
 
+// Connect connects with the stored credentials. Without credentials it starts pairing,
+// see StartPairing, and returns without waiting for a QR code to be scanned.
 func (m *Meow) Connect() {
 	if m.Client.Store.ID == nil {
 		zap.S().Info("No credential found, creating new device")
 		// No ID stored, new login
-		qrChan, err := m.Client.GetQRChannel(context.Background())
-		err = m.Client.Connect()
-		if err != nil {
-			zap.S().Info("Connecting to WhatsApp")
+		if err := m.StartPairing(); err != nil {
 			zap.S().Panicf("Failed to connect to WhatsApp: %s", err)
 			panic(err)
 		}
-		for evt := range qrChan {
-			if evt.Event == "code" {
-				// Render the QR code here
-				// e.g. qrterminal.GenerateHalfBlock(evt.Code, qrterminal.L, os.Stdout)
-				// or just manually `echo 2@... | qrencode -t ansiutf8` in a terminal
-				qrterminal.GenerateHalfBlock(evt.Code, qrterminal.L, os.Stdout)
-				fmt.Println("QR code:", evt.Code)
-			} else {
-				fmt.Println("Login event:", evt.Event)
-			}
-		}
 	} else {
 		// Already logged in, just connect
 		zap.S().Info("Connecting to WhatsApp")
-		err := m.Client.Connect()
+		err := m.connect()
 		if err != nil {
 			zap.S().Panicf("Failed to connect to WhatsApp: %s", err)
 			panic(err)
 		}
-
-		m.Client.AddEventHandler(m.eventHandler)
 	}
 }
 
@@ -161,42 +184,42 @@ func (m *Meow) Exit() {
 	m.Client.Disconnect()
 }
 
-func (m *Meow) SendMessage(message PendingMessage) error {
+func (m *Meow) SendMessage(message PendingMessage) (whatsmeow.SendResponse, error) {
 	zap.S().Debugf("Sending message with ID: %s and content: %s to: %s", message.MessageId, message.Message, message.To)
 
-	newJid := types.NewJID(message.To, "s.whatsapp.net")
-	newMessage := &waProto.Message{
-		ExtendedTextMessage: &waProto.ExtendedTextMessage{
-			Text: proto.String(message.Message),
-		},
+	newJid, err := recipientJID(message.To)
+	if err != nil {
+		return whatsmeow.SendResponse{}, err
 	}
 
-	_, err := m.Client.SendMessage(context.Background(), newJid, newMessage)
+	newMessage, err := m.buildMessage(message)
 	if err != nil {
 		zap.S().Errorf(err.Error())
-		return err
+		return whatsmeow.SendResponse{}, err
 	}
 
-	return nil
+	resp, err := m.Client.SendMessage(context.Background(), newJid, newMessage, whatsmeow.SendRequestExtra{
+		ID: message.MessageId,
+	})
+	if err != nil {
+		zap.S().Errorf(err.Error())
+		return resp, err
+	}
+
+	return resp, nil
 }
 
 func (m *Meow) eventHandler(evt interface{}) {
+	m.handleConnectionEvent(evt)
+	m.publishEvent(evt)
+
 	switch v := evt.(type) {
 
 	case *events.Message:
 		zap.S().Debugf("Received a message: %s", v.Message.GetConversation())
+		go m.handleIncomingMessage(v)
 
 	case *events.Receipt:
-		if v.Type == events.ReceiptTypeRead {
-			zap.S().Debugf("Received a read receipt [%s]", v.MessageIDs)
-
-			go func() {
-				for _, messageId := range v.MessageIDs {
-					m.DB.Model(&models.Message{}).
-						Where("message_id = ?", messageId).
-						Update("read", true)
-				}
-			}()
-		}
+		go m.handleReceipt(v)
 	}
 }
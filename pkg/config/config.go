@@ -8,7 +8,12 @@ import (
 	"go.mau.fi/whatsmeow/store/sqlstore"
 	waLog "go.mau.fi/whatsmeow/util/log"
 	"go.uber.org/zap"
+	"gomeow/pkg/mailer"
+	"gomeow/pkg/storage"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	_ "github.com/jinzhu/gorm/dialects/mysql"
 	_ "github.com/mattn/go-sqlite3"
@@ -18,6 +23,9 @@ type Config struct {
 	appEnv string
 	dbName string
 
+	appTimezone        string
+	defaultCountryCode string
+
 	msgstoreUser string
 	msgstorePswd string
 	msgstorePort string
@@ -25,6 +33,53 @@ type Config struct {
 	msgstoreName string
 
 	apiPort string
+
+	sendMaxAttempts int
+	deliveryWindows string
+
+	webhookMaxAttempts int
+	webhookBackoff     int
+	webhookTimeout     int
+
+	storageDriver    string
+	storageLocalPath string
+	s3Endpoint       string
+	s3Region         string
+	s3Bucket         string
+	s3AccessKey      string
+	s3SecretKey      string
+	s3PathStyle      bool
+	mediaDownload    bool
+	mediaMaxSize     int
+
+	apiToken        string
+	signatureWindow int
+	rateLimitKey    int
+	rateLimitIP     int
+	legacyGetSend   bool
+
+	contactCheckTTL int
+	preSendCheck    bool
+
+	humanizeSending   bool
+	typingSpeed       int
+	typingMaxDelay    int
+	presenceAfterSend string
+
+	streamHistorySize int
+
+	autoReplyEnabled  bool
+	autoReplyCooldown int
+
+	optOutKeywords string
+	optInKeywords  string
+
+	alertEmails  string
+	smtpHost     string
+	smtpPort     string
+	smtpUsername string
+	smtpPassword string
+	smtpFrom     string
 }
 
 func Get() *Config {
@@ -33,12 +88,75 @@ func Get() *Config {
 	/** App Environment **/
 	flag.StringVar(&conf.appEnv, "appenv", getenv("APP_ENV", "production"), "Application Environment")
 
+	/** Timezone used for times of day, e.g. Asia/Jakarta **/
+	flag.StringVar(&conf.appTimezone, "appTimezone", getenv("APP_TIMEZONE", "Local"), "Application Timezone")
+
+	/** Country code of national phone numbers, e.g. 62 turns 0812... into 62812... **/
+	flag.StringVar(&conf.defaultCountryCode, "defaultCountryCode", getenv("DEFAULT_COUNTRY_CODE", ""), "Country code completing national phone numbers")
+
 	/** Database Configurations **/
 	flag.StringVar(&conf.dbName, "dbname", getenv("DB_DATABASE", "meow.db"), "DB name")
 
 	/** API Port Config **/
 	flag.StringVar(&conf.apiPort, "apiPort", getenv("API_PORT", "8080"), "API Port")
 
+	/** Queue Config **/
+	flag.IntVar(&conf.sendMaxAttempts, "sendMaxAttempts", getenvInt("SEND_MAX_ATTEMPTS", 5), "Send attempts before a message is marked as failed")
+	flag.StringVar(&conf.deliveryWindows, "deliveryWindows", getenv("DELIVERY_WINDOWS", ""), "Delivery windows per message category, e.g. default=07:00-21:00,promo=09:00-20:00")
+
+	/** Webhook Config **/
+	flag.IntVar(&conf.webhookMaxAttempts, "webhookMaxAttempts", getenvInt("WEBHOOK_MAX_ATTEMPTS", 5), "Delivery attempts per webhook event")
+	flag.IntVar(&conf.webhookBackoff, "webhookBackoff", getenvInt("WEBHOOK_BACKOFF", 5), "Seconds before the first webhook retry, doubled on every retry")
+	flag.IntVar(&conf.webhookTimeout, "webhookTimeout", getenvInt("WEBHOOK_TIMEOUT", 10), "Webhook request timeout in seconds")
+
+	/** Media Storage Config **/
+	flag.StringVar(&conf.storageDriver, "storageDriver", getenv("STORAGE_DRIVER", "local"), "Media storage driver, local or s3")
+	flag.StringVar(&conf.storageLocalPath, "storageLocalPath", getenv("STORAGE_LOCAL_PATH", "media"), "Media directory of the local storage driver")
+	flag.StringVar(&conf.s3Endpoint, "s3Endpoint", getenv("S3_ENDPOINT", "https://s3.amazonaws.com"), "S3 compatible endpoint")
+	flag.StringVar(&conf.s3Region, "s3Region", getenv("S3_REGION", "us-east-1"), "S3 region")
+	flag.StringVar(&conf.s3Bucket, "s3Bucket", getenv("S3_BUCKET", ""), "S3 bucket")
+	flag.StringVar(&conf.s3AccessKey, "s3AccessKey", getenv("S3_ACCESS_KEY", ""), "S3 access key")
+	flag.StringVar(&conf.s3SecretKey, "s3SecretKey", getenv("S3_SECRET_KEY", ""), "S3 secret key")
+	flag.BoolVar(&conf.s3PathStyle, "s3PathStyle", getenvBool("S3_PATH_STYLE", true), "Use path style S3 URLs, required by MinIO")
+	flag.BoolVar(&conf.mediaDownload, "mediaDownload", getenvBool("MEDIA_DOWNLOAD", true), "Download media of incoming messages")
+	flag.IntVar(&conf.mediaMaxSize, "mediaMaxSize", getenvInt("MEDIA_MAX_SIZE", 100), "Largest incoming media downloaded in MB, 0 downloads any size")
+
+	/** API Auth Config **/
+	flag.StringVar(&conf.apiToken, "apiToken", getenv("API_TOKEN", ""), "Bootstrap admin key, used to issue API keys")
+	flag.IntVar(&conf.signatureWindow, "signatureWindow", getenvInt("SIGNATURE_WINDOW", 300), "Seconds a signed request is accepted before or after its timestamp")
+	flag.IntVar(&conf.rateLimitKey, "rateLimitKey", getenvInt("RATE_LIMIT_KEY", 120), "Requests per minute and API key, 0 disables the limit")
+	flag.IntVar(&conf.rateLimitIP, "rateLimitIP", getenvInt("RATE_LIMIT_IP", 300), "Requests per minute and client IP, 0 disables the limit")
+	flag.BoolVar(&conf.legacyGetSend, "legacyGetSend", getenvBool("LEGACY_GET_SEND", true), "Keep sending with GET /api/v1/messages, deprecated in favour of POST")
+
+	/** Contact Check Config **/
+	flag.IntVar(&conf.contactCheckTTL, "contactCheckTTL", getenvInt("CONTACT_CHECK_TTL", 21600), "Seconds a WhatsApp registration lookup is cached")
+	flag.BoolVar(&conf.preSendCheck, "preSendCheck", getenvBool("PRE_SEND_CHECK", false), "Fail messages to numbers that aren't on WhatsApp instead of sending them")
+
+	/** Humanized Sending Config **/
+	flag.BoolVar(&conf.humanizeSending, "humanizeSending", getenvBool("HUMANIZE_SENDING", false), "Show the session typing before sending, one message at a time")
+	flag.IntVar(&conf.typingSpeed, "typingSpeed", getenvInt("TYPING_SPEED", 15), "Characters typed per second")
+	flag.IntVar(&conf.typingMaxDelay, "typingMaxDelay", getenvInt("TYPING_MAX_DELAY", 8), "Longest typing time in seconds")
+	flag.StringVar(&conf.presenceAfterSend, "presenceAfterSend", getenv("PRESENCE_AFTER_SEND", ""), "Presence after sending, available or unavailable, empty leaves it")
+
+	/** Event Stream Config **/
+	flag.IntVar(&conf.streamHistorySize, "streamHistorySize", getenvInt("STREAM_HISTORY_SIZE", 1000), "Events kept for resuming event streams")
+
+	/** Auto Reply Config **/
+	flag.BoolVar(&conf.autoReplyEnabled, "autoReplyEnabled", getenvBool("AUTOREPLY_ENABLED", true), "Answer incoming messages with auto reply rules")
+	flag.IntVar(&conf.autoReplyCooldown, "autoReplyCooldown", getenvInt("AUTOREPLY_COOLDOWN", 60), "Seconds before a rule replies to the same sender again, unless the rule sets its own")
+
+	/** Opt-out Config, comma separated keywords **/
+	flag.StringVar(&conf.optOutKeywords, "optOutKeywords", getenv("OPT_OUT_KEYWORDS", "STOP,BERHENTI"), "Keywords adding the sender to the suppression list")
+	flag.StringVar(&conf.optInKeywords, "optInKeywords", getenv("OPT_IN_KEYWORDS", "START"), "Keywords removing the sender from the suppression list")
+
+	/** Connection Alert Config, alerts are mailed when a session is logged out or banned **/
+	flag.StringVar(&conf.alertEmails, "alertEmails", getenv("ALERT_EMAILS", ""), "Comma separated recipients of connection alerts")
+	flag.StringVar(&conf.smtpHost, "smtpHost", getenv("SMTP_HOST", ""), "SMTP host")
+	flag.StringVar(&conf.smtpPort, "smtpPort", getenv("SMTP_PORT", "587"), "SMTP port")
+	flag.StringVar(&conf.smtpUsername, "smtpUsername", getenv("SMTP_USERNAME", ""), "SMTP user name")
+	flag.StringVar(&conf.smtpPassword, "smtpPassword", getenv("SMTP_PASSWORD", ""), "SMTP password")
+	flag.StringVar(&conf.smtpFrom, "smtpFrom", getenv("SMTP_FROM", ""), "Sender address of alert emails")
+
 	/**
 	 * Message Store Database
 	 * Using MySQL database
@@ -68,10 +186,44 @@ func getenv(key, fallback string) string {
 	return fallback
 }
 
+func getenvInt(key string, fallback int) int {
+	if value, ok := os.LookupEnv(key); ok {
+		if intValue, err := strconv.Atoi(value); err == nil {
+			return intValue
+		}
+	}
+
+	return fallback
+}
+
+func getenvBool(key string, fallback bool) bool {
+	if value, ok := os.LookupEnv(key); ok {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+
+	return fallback
+}
+
 func (c *Config) GetAppEnv() string {
 	return c.appEnv
 }
 
+func (c *Config) GetLocation() *time.Location {
+	location, err := time.LoadLocation(c.appTimezone)
+	if err != nil {
+		zap.S().Warnf("Unknown timezone %s, using local time", c.appTimezone)
+		return time.Local
+	}
+
+	return location
+}
+
+func (c *Config) GetDefaultCountryCode() string {
+	return c.defaultCountryCode
+}
+
 func (c *Config) GetDBConnStr() string {
 	return "file:" + c.dbName + "?_foreign_keys=on"
 }
@@ -91,6 +243,141 @@ func (c *Config) GetAPIPort() string {
 	return ":" + c.apiPort
 }
 
+func (c *Config) GetSendMaxAttempts() int {
+	return c.sendMaxAttempts
+}
+
+// GetDeliveryWindows returns the "category=HH:MM-HH:MM" delivery windows.
+func (c *Config) GetDeliveryWindows() []string {
+	return splitList(c.deliveryWindows)
+}
+
+func (c *Config) GetWebhookMaxAttempts() int {
+	return c.webhookMaxAttempts
+}
+
+func (c *Config) GetWebhookBackoff() time.Duration {
+	return time.Duration(c.webhookBackoff) * time.Second
+}
+
+func (c *Config) GetWebhookTimeout() time.Duration {
+	return time.Duration(c.webhookTimeout) * time.Second
+}
+
+func (c *Config) GetMediaDownload() bool {
+	return c.mediaDownload
+}
+
+// GetMediaMaxSize returns the largest incoming media downloaded in bytes, 0 means any size.
+func (c *Config) GetMediaMaxSize() uint64 {
+	if c.mediaMaxSize <= 0 {
+		return 0
+	}
+
+	return uint64(c.mediaMaxSize) << 20
+}
+
+func (c *Config) GetAPIToken() string {
+	return c.apiToken
+}
+
+func (c *Config) GetSignatureWindow() time.Duration {
+	return time.Duration(c.signatureWindow) * time.Second
+}
+
+func (c *Config) GetLegacyGetSend() bool {
+	return c.legacyGetSend
+}
+
+func (c *Config) GetContactCheckTTL() time.Duration {
+	return time.Duration(c.contactCheckTTL) * time.Second
+}
+
+func (c *Config) GetPreSendCheck() bool {
+	return c.preSendCheck
+}
+
+func (c *Config) GetHumanizeSending() bool {
+	return c.humanizeSending
+}
+
+func (c *Config) GetTypingSpeed() int {
+	return c.typingSpeed
+}
+
+func (c *Config) GetTypingMaxDelay() time.Duration {
+	return time.Duration(c.typingMaxDelay) * time.Second
+}
+
+func (c *Config) GetPresenceAfterSend() string {
+	return c.presenceAfterSend
+}
+
+func (c *Config) GetRateLimitKey() int {
+	return c.rateLimitKey
+}
+
+func (c *Config) GetRateLimitIP() int {
+	return c.rateLimitIP
+}
+
+func (c *Config) GetStreamHistorySize() int {
+	return c.streamHistorySize
+}
+
+func (c *Config) GetAutoReplyEnabled() bool {
+	return c.autoReplyEnabled
+}
+
+func (c *Config) GetAutoReplyCooldown() time.Duration {
+	return time.Duration(c.autoReplyCooldown) * time.Second
+}
+
+func (c *Config) GetOptOutKeywords() []string {
+	return splitList(c.optOutKeywords)
+}
+
+func (c *Config) GetOptInKeywords() []string {
+	return splitList(c.optInKeywords)
+}
+
+// GetMailer returns the mailer of connection alerts, or nil if alert emails aren't configured.
+func (c *Config) GetMailer() *mailer.Mailer {
+	return mailer.New(c.smtpHost, c.smtpPort, c.smtpUsername, c.smtpPassword, c.smtpFrom, splitList(c.alertEmails))
+}
+
+func splitList(value string) []string {
+	var values []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); len(v) > 0 {
+			values = append(values, v)
+		}
+	}
+
+	return values
+}
+
+func (c *Config) ConnectToStorage() storage.Storage {
+	var store storage.Storage
+	var err error
+
+	switch c.storageDriver {
+	case "s3":
+		zap.S().Debugf("Using s3 media storage @ %s/%s", c.s3Endpoint, c.s3Bucket)
+		store, err = storage.NewS3(c.s3Endpoint, c.s3Region, c.s3Bucket, c.s3AccessKey, c.s3SecretKey, c.s3PathStyle)
+	default:
+		zap.S().Debugf("Using local media storage @ %s", c.storageLocalPath)
+		store, err = storage.NewLocal(c.storageLocalPath)
+	}
+
+	if err != nil {
+		zap.S().Panicf("Failed to initialize media storage: %s", err)
+		panic(err)
+	}
+
+	return store
+}
+
 func (c *Config) ConnectToDatabase() *sqlstore.Container {
 
 	logLevel := "ERROR"
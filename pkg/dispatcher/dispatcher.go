@@ -0,0 +1,76 @@
+package dispatcher
+
+import (
+	"go.uber.org/zap"
+	"sync"
+)
+
+// Handler receives every event of the WhatsApp client, e.g. *events.Message.
+// Handlers run on the event loop of the client, so slow work belongs in a goroutine.
+type Handler func(evt interface{})
+
+// Dispatcher fans the events of the WhatsApp client out to the subscribed handlers.
+// It is registered with the client once, before connecting, so handlers
+// subscribed at any time receive events of both paired and freshly paired devices.
+type Dispatcher struct {
+	mu       sync.RWMutex
+	nextId   uint64
+	handlers []subscription
+}
+
+type subscription struct {
+	id      uint64
+	handler Handler
+}
+
+func New() *Dispatcher {
+	return &Dispatcher{}
+}
+
+// Subscribe adds the handler, returning a function removing it again.
+func (d *Dispatcher) Subscribe(handler Handler) func() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.nextId++
+	id := d.nextId
+	d.handlers = append(d.handlers, subscription{id: id, handler: handler})
+
+	return func() {
+		d.unsubscribe(id)
+	}
+}
+
+func (d *Dispatcher) unsubscribe(id uint64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for i, s := range d.handlers {
+		if s.id == id {
+			d.handlers = append(d.handlers[:i:i], d.handlers[i+1:]...)
+			return
+		}
+	}
+}
+
+// Dispatch passes the event to the handlers in the order they subscribed.
+// A panicking handler is logged and doesn't keep the event from the others.
+func (d *Dispatcher) Dispatch(evt interface{}) {
+	d.mu.RLock()
+	handlers := d.handlers
+	d.mu.RUnlock()
+
+	for _, s := range handlers {
+		d.call(s.handler, evt)
+	}
+}
+
+func (d *Dispatcher) call(handler Handler, evt interface{}) {
+	defer func() {
+		if err := recover(); err != nil {
+			zap.S().Errorf("Event handler panicked on %T: %v", evt, err)
+		}
+	}()
+
+	handler(evt)
+}
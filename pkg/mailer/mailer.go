@@ -0,0 +1,62 @@
+package mailer
+
+import (
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// Mailer sends plain text emails through an SMTP server.
+type Mailer struct {
+	addr     string
+	host     string
+	username string
+	password string
+	from     string
+	to       []string
+}
+
+// New returns a mailer, or nil when no host or recipient is configured.
+func New(host string, port string, username string, password string, from string, to []string) *Mailer {
+	if len(host) == 0 || len(to) == 0 {
+		return nil
+	}
+
+	if len(from) == 0 {
+		from = username
+	}
+
+	return &Mailer{
+		addr:     net.JoinHostPort(host, port),
+		host:     host,
+		username: username,
+		password: password,
+		from:     from,
+		to:       to,
+	}
+}
+
+func (m *Mailer) Send(subject string, body string) error {
+	var auth smtp.Auth
+	if len(m.username) > 0 {
+		auth = smtp.PlainAuth("", m.username, m.password, m.host)
+	}
+
+	var message strings.Builder
+	message.WriteString("From: " + m.from + "\r\n")
+	message.WriteString("To: " + strings.Join(m.to, ", ") + "\r\n")
+	message.WriteString("Subject: " + strings.NewReplacer("\r", "", "\n", " ").Replace(subject) + "\r\n")
+	message.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	message.WriteString("MIME-Version: 1.0\r\n")
+	message.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	message.WriteString("\r\n")
+	message.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	if err := smtp.SendMail(m.addr, auth, m.from, m.to, []byte(message.String())); err != nil {
+		return fmt.Errorf("mailer: %w", err)
+	}
+
+	return nil
+}
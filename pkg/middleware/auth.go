@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"github.com/julienschmidt/httprouter"
+	"go.uber.org/zap"
+	"gomeow/cmd/models"
+	"gomeow/pkg/apikey"
+	"gomeow/pkg/server"
+	"net/http"
+	"strings"
+)
+
+type contextKey string
+
+const apiKeyContextKey contextKey = "api_key"
+
+// RequireScope rejects requests that don't carry a usable API key with the scope as a bearer token,
+// unless VerifySignature already authenticated them. The Bearer prefix is optional,
+// as clients of the wablas compatible API send the bare key.
+func RequireScope(keys *apikey.Store, scope string) Middleware {
+	return func(next httprouter.Handle) httprouter.Handle {
+		return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+			key := ApiKey(r)
+			if key == nil {
+				var err error
+				key, err = keys.Authenticate(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+				if err != nil {
+					if !errors.Is(err, apikey.ErrInvalidKey) {
+						authenticationFailed(w, err)
+						return
+					}
+
+					w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+					w.Header().Set("WWW-Authenticate", "Bearer")
+					server.SendHttpResp(w, "Unauthorized", http.StatusUnauthorized)
+					return
+				}
+			}
+
+			if !key.HasScope(scope) {
+				w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+				server.SendHttpResp(w, "Missing Scope "+scope, http.StatusForbidden)
+				return
+			}
+
+			next(w, r.WithContext(context.WithValue(r.Context(), apiKeyContextKey, key)), p)
+		}
+	}
+}
+
+// authenticationFailed answers requests whose key couldn't be checked, e.g. while the database is down.
+func authenticationFailed(w http.ResponseWriter, err error) {
+	zap.S().Errorf("Failed to authenticate request: %s", err)
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	server.SendHttpResp(w, "Internal Server Error", http.StatusInternalServerError)
+}
+
+// ApiKey returns the key the request has been authenticated with, if any.
+func ApiKey(r *http.Request) *models.ApiKey {
+	key, _ := r.Context().Value(apiKeyContextKey).(*models.ApiKey)
+	return key
+}
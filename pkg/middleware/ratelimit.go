@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"github.com/julienschmidt/httprouter"
+	"gomeow/pkg/ratelimit"
+	"gomeow/pkg/server"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// LimitIP rate limits requests per client IP. Chain it before the authentication, to slow down guessing keys.
+// The IP is the remote address of the connection, proxies in front of the gateway share one limit.
+func LimitIP(limiter *ratelimit.Limiter) Middleware {
+	return func(next httprouter.Handle) httprouter.Handle {
+		if limiter == nil {
+			return next
+		}
+
+		return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+			ip, _, err := net.SplitHostPort(r.RemoteAddr)
+			if err != nil {
+				ip = r.RemoteAddr
+			}
+
+			if ok, retryAfter := limiter.Allow(ip); !ok {
+				TooManyRequests(w, "Too Many Requests", retryAfter)
+				return
+			}
+
+			next(w, r, p)
+		}
+	}
+}
+
+// LimitKey rate limits requests per API key. Chain it after RequireScope.
+func LimitKey(limiter *ratelimit.Limiter) Middleware {
+	return func(next httprouter.Handle) httprouter.Handle {
+		if limiter == nil {
+			return next
+		}
+
+		return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+			if key := ApiKey(r); key != nil {
+				if ok, retryAfter := limiter.Allow(strconv.FormatInt(key.ID, 10)); !ok {
+					TooManyRequests(w, "Too Many Requests", retryAfter)
+					return
+				}
+			}
+
+			next(w, r, p)
+		}
+	}
+}
+
+// TooManyRequests rejects the request with a 429, telling the client when to retry in whole seconds.
+func TooManyRequests(w http.ResponseWriter, message string, retryAfter time.Duration) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Max(1, math.Ceil(retryAfter.Seconds())))))
+	server.SendHttpResp(w, message, http.StatusTooManyRequests)
+}
@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"gomeow/pkg/ratelimit"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTooManyRequestsRetryAfter(t *testing.T) {
+	tests := []struct {
+		retryAfter time.Duration
+		header     string
+	}{
+		{0, "1"},
+		{300 * time.Millisecond, "1"},
+		{time.Second, "1"},
+		{1500 * time.Millisecond, "2"},
+		{20 * time.Second, "20"},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		TooManyRequests(w, "Too Many Requests", test.retryAfter)
+
+		if w.Code != http.StatusTooManyRequests {
+			t.Errorf("%s: expected status 429, got %d", test.retryAfter, w.Code)
+		}
+
+		if header := w.Header().Get("Retry-After"); header != test.header {
+			t.Errorf("%s: expected Retry-After %s, got %s", test.retryAfter, test.header, header)
+		}
+	}
+}
+
+func TestLimitIP(t *testing.T) {
+	handler := LimitIP(ratelimit.New(1))(echo)
+
+	tests := []struct {
+		remoteAddr string
+		status     int
+	}{
+		{"192.0.2.1:1234", http.StatusOK},
+		{"192.0.2.1:5678", http.StatusTooManyRequests},
+		{"192.0.2.2:1234", http.StatusOK},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/messages", nil)
+		r.RemoteAddr = test.remoteAddr
+
+		w := httptest.NewRecorder()
+		handler(w, r, nil)
+
+		if w.Code != test.status {
+			t.Errorf("%s: expected status %d, got %d", test.remoteAddr, test.status, w.Code)
+		}
+
+		if test.status == http.StatusTooManyRequests && len(w.Header().Get("Retry-After")) == 0 {
+			t.Errorf("%s: expected a Retry-After header", test.remoteAddr)
+		}
+	}
+}
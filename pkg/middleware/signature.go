@@ -0,0 +1,132 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/julienschmidt/httprouter"
+	"gomeow/pkg/apikey"
+	"gomeow/pkg/server"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Headers of signed requests
+const (
+	HeaderKeyId     = "X-Key-Id"
+	HeaderTimestamp = "X-Timestamp"
+	HeaderSignature = "X-Signature"
+)
+
+// maxSignedBodySize fits the largest send request, a base64 encoded document.
+// Signed bodies are read before authenticating, so they are capped here too.
+const maxSignedBodySize = 140 << 20
+
+// VerifySignature authenticates requests signed with the signing secret of an API key,
+// as an alternative to sending the key. The X-Signature header is the hex encoded HMAC-SHA256 of
+//
+//	METHOD \n REQUEST URI \n X-Timestamp \n hex SHA-256 of the body
+//
+// where the request URI includes the query and the timestamp is in unix seconds. Requests whose
+// timestamp is further off than the window are rejected, and so are signatures seen before.
+// Requests without a signature are passed on unchanged, so RequireScope can check their bearer key.
+func VerifySignature(keys *apikey.Store, window time.Duration) Middleware {
+	seen := &seenSignatures{signatures: make(map[string]time.Time)}
+
+	return func(next httprouter.Handle) httprouter.Handle {
+		return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+			signature := r.Header.Get(HeaderSignature)
+			if len(signature) == 0 {
+				next(w, r, p)
+				return
+			}
+
+			timestamp := r.Header.Get(HeaderTimestamp)
+			seconds, err := strconv.ParseInt(timestamp, 10, 64)
+			if err != nil {
+				rejectSignature(w, "Invalid Timestamp")
+				return
+			}
+
+			signedAt := time.Unix(seconds, 0)
+			if signedAt.Before(time.Now().Add(-window)) || signedAt.After(time.Now().Add(window)) {
+				rejectSignature(w, "Request Expired")
+				return
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxSignedBodySize))
+			r.Body.Close()
+			if err != nil {
+				var maxBytesError *http.MaxBytesError
+				if errors.As(err, &maxBytesError) {
+					w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+					server.SendHttpResp(w, "Request Entity Too Large", http.StatusRequestEntityTooLarge)
+					return
+				}
+
+				rejectSignature(w, "Unreadable Body")
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			bodyHash := sha256.Sum256(body)
+			payload := strings.Join([]string{r.Method, r.URL.RequestURI(), timestamp, hex.EncodeToString(bodyHash[:])}, "\n")
+
+			key, err := keys.AuthenticateSignature(r.Header.Get(HeaderKeyId), payload, signature)
+			if err != nil {
+				if !errors.Is(err, apikey.ErrInvalidKey) && !errors.Is(err, apikey.ErrInvalidSignature) {
+					authenticationFailed(w, err)
+					return
+				}
+
+				rejectSignature(w, "Unauthorized")
+				return
+			}
+
+			if !seen.add(signature, signedAt.Add(window)) {
+				rejectSignature(w, "Replayed Request")
+				return
+			}
+
+			next(w, r.WithContext(context.WithValue(r.Context(), apiKeyContextKey, key)), p)
+		}
+	}
+}
+
+func rejectSignature(w http.ResponseWriter, message string) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	server.SendHttpResp(w, message, http.StatusUnauthorized)
+}
+
+// seenSignatures remembers signatures until their request would be expired anyway.
+type seenSignatures struct {
+	mu         sync.Mutex
+	signatures map[string]time.Time
+}
+
+// add returns false when the signature has been seen before.
+func (s *seenSignatures) add(signature string, expiresAt time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for sig, expiry := range s.signatures {
+		if now.After(expiry) {
+			delete(s.signatures, sig)
+		}
+	}
+
+	signature = strings.ToLower(signature)
+	if _, ok := s.signatures[signature]; ok {
+		return false
+	}
+	s.signatures[signature] = expiresAt
+
+	return true
+}
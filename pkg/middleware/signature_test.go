@@ -0,0 +1,174 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/julienschmidt/httprouter"
+	"gomeow/cmd/models"
+	"gomeow/pkg/apikey"
+	"gomeow/pkg/testdb"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testWindow = 5 * time.Minute
+
+func newTestKeys(t *testing.T) (*apikey.Store, *models.ApiKey) {
+	keys := apikey.NewStore(testdb.Open(t, &models.ApiKey{}), "", time.UTC)
+	key := &models.ApiKey{Name: "test", Scopes: models.ApiScopeSend, Signing: true}
+	if _, err := keys.Issue(key); err != nil {
+		t.Fatal(err)
+	}
+
+	return keys, key
+}
+
+func signedRequest(key *models.ApiKey, method string, uri string, body string, signedAt time.Time) *http.Request {
+	timestamp := strconv.FormatInt(signedAt.Unix(), 10)
+	bodyHash := sha256.Sum256([]byte(body))
+	payload := strings.Join([]string{method, uri, timestamp, hex.EncodeToString(bodyHash[:])}, "\n")
+
+	r := httptest.NewRequest(method, uri, strings.NewReader(body))
+	r.Header.Set(HeaderKeyId, strconv.FormatInt(key.ID, 10))
+	r.Header.Set(HeaderTimestamp, timestamp)
+	r.Header.Set(HeaderSignature, apikey.Sign(key.SigningSecret, payload))
+
+	return r
+}
+
+// echo responds with the body it reads, and whether the request has been authenticated.
+func echo(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	body, _ := io.ReadAll(r.Body)
+	if ApiKey(r) != nil {
+		w.Header().Set("X-Authenticated", "true")
+	}
+	_, _ = w.Write(body)
+}
+
+func TestVerifySignature(t *testing.T) {
+	keys, key := newTestKeys(t)
+	now := time.Now()
+
+	tests := []struct {
+		name          string
+		request       func() *http.Request
+		status        int
+		authenticated bool
+	}{
+		{
+			name: "valid",
+			request: func() *http.Request {
+				return signedRequest(key, http.MethodPost, "/api/v1/messages?session=a", `{"to":"62812"}`, now)
+			},
+			status:        http.StatusOK,
+			authenticated: true,
+		},
+		{
+			name: "within the window",
+			request: func() *http.Request {
+				return signedRequest(key, http.MethodPost, "/api/v1/messages", `{}`, now.Add(-testWindow+time.Minute))
+			},
+			status:        http.StatusOK,
+			authenticated: true,
+		},
+		{
+			name: "expired",
+			request: func() *http.Request {
+				return signedRequest(key, http.MethodPost, "/api/v1/messages", `{}`, now.Add(-testWindow-time.Minute))
+			},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name: "from the future",
+			request: func() *http.Request {
+				return signedRequest(key, http.MethodPost, "/api/v1/messages", `{}`, now.Add(testWindow+time.Minute))
+			},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name: "invalid timestamp",
+			request: func() *http.Request {
+				r := signedRequest(key, http.MethodPost, "/api/v1/messages", `{}`, now)
+				r.Header.Set(HeaderTimestamp, "yesterday")
+				return r
+			},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name: "tampered body",
+			request: func() *http.Request {
+				r := signedRequest(key, http.MethodPost, "/api/v1/messages", `{"to":"62812"}`, now)
+				r.Body = io.NopCloser(strings.NewReader(`{"to":"62813"}`))
+				return r
+			},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name: "unknown key",
+			request: func() *http.Request {
+				r := signedRequest(key, http.MethodGet, "/api/v1/messages", "", now)
+				r.Header.Set(HeaderKeyId, "999")
+				return r
+			},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name: "unsigned",
+			request: func() *http.Request {
+				return httptest.NewRequest(http.MethodPost, "/api/v1/messages", strings.NewReader(`{}`))
+			},
+			status: http.StatusOK,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			handler := VerifySignature(keys, testWindow)(echo)
+			r := test.request()
+			sent, _ := io.ReadAll(r.Body)
+			r.Body = io.NopCloser(strings.NewReader(string(sent)))
+
+			w := httptest.NewRecorder()
+			handler(w, r, nil)
+
+			if w.Code != test.status {
+				t.Fatalf("expected status %d, got %d: %s", test.status, w.Code, w.Body.String())
+			}
+
+			if authenticated := w.Header().Get("X-Authenticated") == "true"; authenticated != test.authenticated {
+				t.Errorf("expected authenticated to be %t", test.authenticated)
+			}
+
+			// the handler reads the same body the signature has been checked against
+			if w.Code == http.StatusOK && w.Body.String() != string(sent) {
+				t.Errorf("expected the body %q to be rewound, got %q", sent, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestVerifySignatureRejectsReplays(t *testing.T) {
+	keys, key := newTestKeys(t)
+	handler := VerifySignature(keys, testWindow)(echo)
+	now := time.Now()
+
+	first := signedRequest(key, http.MethodPost, "/api/v1/messages", `{"to":"62812"}`, now)
+	replay := signedRequest(key, http.MethodPost, "/api/v1/messages", `{"to":"62812"}`, now)
+
+	w := httptest.NewRecorder()
+	handler(w, first, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected the first request to pass, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	handler(w, replay, nil)
+	if w.Code != http.StatusUnauthorized || !strings.Contains(w.Body.String(), "Replayed Request") {
+		t.Fatalf("expected the replay to be rejected, got %d: %s", w.Code, w.Body.String())
+	}
+}
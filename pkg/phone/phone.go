@@ -0,0 +1,78 @@
+package phone
+
+import (
+	"errors"
+	"strings"
+)
+
+var ErrInvalidPhone = errors.New("invalid phone number")
+
+// E.164 numbers have up to 15 digits, including the country code.
+const (
+	minDigits = 7
+	maxDigits = 15
+)
+
+// punctuation is what people write between the digits of a number.
+var punctuation = strings.NewReplacer(" ", "", "-", "", ".", "", "(", "", ")", "", "/", "", "\u00a0", "")
+
+// Normalizer turns phone numbers as people write them into E.164 digits, without the plus,
+// which is the user part of a WhatsApp JID.
+type Normalizer struct {
+	countryCode string
+}
+
+// New returns a normalizer completing national numbers, which start with the trunk prefix 0,
+// with the country code. Without a country code, national numbers are rejected.
+func New(defaultCountryCode string) *Normalizer {
+	return &Normalizer{countryCode: strings.TrimPrefix(strings.TrimSpace(defaultCountryCode), "+")}
+}
+
+// Normalize accepts
+//
+//	+62 812-3456-789, +62 (0)812 3456 789, 0062 812 3456 789  international, with + or 00
+//	62812-3456-789                                             international, the way wablas expects it
+//	0812-3456-789                                              national, with the trunk prefix 0
+func (n *Normalizer) Normalize(raw string) (string, error) {
+	number := strings.TrimSpace(raw)
+
+	// the trunk prefix is sometimes written after the country code, as in +44 (0)20,
+	// without a country code it is the trunk prefix of a national number
+	if strings.HasPrefix(number, "+") || strings.HasPrefix(number, "00") {
+		number = strings.Replace(number, "(0)", "", 1)
+	}
+	number = punctuation.Replace(number)
+
+	switch {
+	case strings.HasPrefix(number, "+"):
+		number = number[1:]
+	case strings.HasPrefix(number, "00"):
+		number = number[2:]
+	case strings.HasPrefix(number, "0"):
+		if len(n.countryCode) == 0 {
+			return "", ErrInvalidPhone
+		}
+		number = n.countryCode + number[1:]
+	}
+
+	if !Valid(number) {
+		return "", ErrInvalidPhone
+	}
+
+	return number, nil
+}
+
+// Valid checks that the number looks like E.164 digits without the plus.
+func Valid(number string) bool {
+	if len(number) < minDigits || len(number) > maxDigits || number[0] == '0' {
+		return false
+	}
+
+	for _, c := range number {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,69 @@
+package phone
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		countryCode string
+		raw         string
+		expected    string
+		err         error
+	}{
+		{"62", "0812-3456-789", "628123456789", nil},
+		{"62", "0812 3456 789", "628123456789", nil},
+		{"62", "(0)812 3456 789", "628123456789", nil},
+		{"62", "+62 812-3456-789", "628123456789", nil},
+		{"62", "+62 (0)812 3456 789", "628123456789", nil},
+		{"62", "0062 812 3456 789", "628123456789", nil},
+		{"62", "0062 (0)812 3456 789", "628123456789", nil},
+		{"62", "62812-3456-789", "628123456789", nil},
+		{"+62", "0812.3456.789", "628123456789", nil},
+		{"44", "+44 (0)20 7946 0958", "442079460958", nil},
+		{"62", " +62 812 3456 789 ", "628123456789", nil},
+		{"", "0812-3456-789", "", ErrInvalidPhone},
+		{"", "(0)812 3456 789", "", ErrInvalidPhone},
+		{"62", "", "", ErrInvalidPhone},
+		{"62", "12345", "", ErrInvalidPhone},
+		{"62", "+62 812 3456 7890 1234", "", ErrInvalidPhone},
+		{"62", "0812-CALL-ME", "", ErrInvalidPhone},
+		{"62", "+0812 3456 789", "", ErrInvalidPhone},
+		{"62", "000812 3456 789", "", ErrInvalidPhone},
+	}
+
+	for _, test := range tests {
+		number, err := New(test.countryCode).Normalize(test.raw)
+		if !errors.Is(err, test.err) {
+			t.Errorf("%q with country code %q: expected error %v, got %v", test.raw, test.countryCode, test.err, err)
+			continue
+		}
+
+		if number != test.expected {
+			t.Errorf("%q with country code %q: expected %q, got %q", test.raw, test.countryCode, test.expected, number)
+		}
+	}
+}
+
+func TestValid(t *testing.T) {
+	tests := []struct {
+		number string
+		valid  bool
+	}{
+		{"628123456789", true},
+		{"1234567", true},
+		{"123456789012345", true},
+		{"123456", false},
+		{"1234567890123456", false},
+		{"0812345678", false},
+		{"+628123456789", false},
+		{"62812345678a", false},
+	}
+
+	for _, test := range tests {
+		if valid := Valid(test.number); valid != test.valid {
+			t.Errorf("%q: expected valid to be %t", test.number, test.valid)
+		}
+	}
+}
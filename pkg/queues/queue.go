@@ -2,16 +2,89 @@ package queues
 
 import (
 	"container/list"
+	"sync"
 )
 
 type Queue struct {
 	Messages *list.List
+	mu       sync.Mutex
 }
 
 func (q *Queue) Add(message interface{}) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
 	q.Messages.PushBack(message)
 }
 
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.Messages.Len()
+}
+
+// Take removes and returns the first message accepted by ready, or nil if none is ready.
+// Messages that aren't ready keep their place in the queue.
+func (q *Queue) Take(ready func(message interface{}) bool) interface{} {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for e := q.Messages.Front(); e != nil; e = e.Next() {
+		if ready(e.Value) {
+			return q.Messages.Remove(e)
+		}
+	}
+
+	return nil
+}
+
+// Remove removes every queued message accepted by match, and returns how many it removed.
+func (q *Queue) Remove(match func(message interface{}) bool) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	removed := 0
+	for e := q.Messages.Front(); e != nil; {
+		next := e.Next()
+		if match(e.Value) {
+			q.Messages.Remove(e)
+			removed++
+		}
+		e = next
+	}
+
+	return removed
+}
+
+// Drain removes and returns every queued message.
+func (q *Queue) Drain() []interface{} {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	messages := make([]interface{}, 0, q.Messages.Len())
+	for e := q.Messages.Front(); e != nil; e = e.Next() {
+		messages = append(messages, e.Value)
+	}
+	q.Messages.Init()
+
+	return messages
+}
+
+// Contains checks whether any queued message is accepted by match.
+func (q *Queue) Contains(match func(message interface{}) bool) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for e := q.Messages.Front(); e != nil; e = e.Next() {
+		if match(e.Value) {
+			return true
+		}
+	}
+
+	return false
+}
+
 func InitQueue() *Queue {
 	return &Queue{
 		Messages: list.New(),
@@ -0,0 +1,93 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewDisabled(t *testing.T) {
+	for _, perMinute := range []int{0, -1} {
+		if New(perMinute) != nil {
+			t.Errorf("expected %d requests per minute to disable the limit", perMinute)
+		}
+	}
+}
+
+func TestAllow(t *testing.T) {
+	type step struct {
+		advance    time.Duration
+		key        string
+		allowed    bool
+		retryAfter time.Duration
+	}
+
+	tests := []struct {
+		name      string
+		perMinute int
+		steps     []step
+	}{
+		{
+			name:      "burst of a minute",
+			perMinute: 3,
+			steps: []step{
+				{key: "a", allowed: true},
+				{key: "a", allowed: true},
+				{key: "a", allowed: true},
+				{key: "a", allowed: false, retryAfter: 20 * time.Second},
+			},
+		},
+		{
+			name:      "refill",
+			perMinute: 3,
+			steps: []step{
+				{key: "a", allowed: true},
+				{key: "a", allowed: true},
+				{key: "a", allowed: true},
+				{advance: 10 * time.Second, key: "a", allowed: false, retryAfter: 10 * time.Second},
+				{advance: 10 * time.Second, key: "a", allowed: true},
+				{key: "a", allowed: false, retryAfter: 20 * time.Second},
+			},
+		},
+		{
+			name:      "refill stops at the burst",
+			perMinute: 2,
+			steps: []step{
+				{key: "a", allowed: true},
+				{advance: time.Hour, key: "a", allowed: true},
+				{key: "a", allowed: true},
+				{key: "a", allowed: false, retryAfter: 30 * time.Second},
+			},
+		},
+		{
+			name:      "keys have their own bucket",
+			perMinute: 1,
+			steps: []step{
+				{key: "a", allowed: true},
+				{key: "a", allowed: false, retryAfter: time.Minute},
+				{key: "b", allowed: true},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+			limiter := New(test.perMinute)
+			limiter.now = func() time.Time { return now }
+
+			for i, s := range test.steps {
+				now = now.Add(s.advance)
+
+				allowed, retryAfter := limiter.Allow(s.key)
+				if allowed != s.allowed {
+					t.Fatalf("step %d: expected allowed to be %t", i, s.allowed)
+				}
+
+				// float rounding may be off by a few nanoseconds
+				if diff := retryAfter - s.retryAfter; diff < -time.Millisecond || diff > time.Millisecond {
+					t.Fatalf("step %d: expected to retry after %s, got %s", i, s.retryAfter, retryAfter)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,15 @@
+package storage
+
+import (
+	"errors"
+	"io"
+)
+
+var ErrNotFound = errors.New("storage: object not found")
+
+// Storage is a backend for media files, addressed by key.
+type Storage interface {
+	Put(key string, contentType string, data []byte) error
+	Get(key string) (io.ReadCloser, error)
+	Delete(key string) error
+}
@@ -0,0 +1,165 @@
+package stream
+
+import (
+	"sync"
+	"time"
+)
+
+// EventResync tells a resuming subscriber that events after its last event are no longer kept,
+// e.g. after a restart, so it has to fetch the current state instead.
+const EventResync = "resync"
+
+type Event struct {
+	Id        uint64      `json:"id"`
+	Session   string      `json:"session,omitempty"`
+	Type      string      `json:"type"`
+	Chat      string      `json:"chat,omitempty"`
+	Timestamp time.Time   `json:"timestamp"`
+	Data      interface{} `json:"data"`
+}
+
+// Filter limits the events a subscriber receives. Empty lists match everything.
+// Events of the excluded types never match, whatever the other lists say.
+type Filter struct {
+	Sessions     []string
+	Types        []string
+	Chats        []string
+	ExcludeTypes []string
+}
+
+func (f Filter) Matches(event Event) bool {
+	if len(f.ExcludeTypes) > 0 && matchesAny(f.ExcludeTypes, event.Type) {
+		return false
+	}
+
+	return matchesAny(f.Sessions, event.Session) && matchesAny(f.Types, event.Type) && matchesAny(f.Chats, event.Chat)
+}
+
+func matchesAny(values []string, value string) bool {
+	if len(values) == 0 {
+		return true
+	}
+
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+type Subscriber struct {
+	Events chan Event
+	filter Filter
+}
+
+// Hub fans out published events to subscribers, and keeps
+// the latest events so that subscribers can resume after reconnecting.
+type Hub struct {
+	mu          sync.Mutex
+	lastId      uint64
+	history     []Event
+	historySize int
+	subscribers map[*Subscriber]struct{}
+}
+
+func NewHub(historySize int) *Hub {
+	// a negative size keeps no history, like zero
+	if historySize < 0 {
+		historySize = 0
+	}
+
+	// ids start at the boot time in microseconds, so they keep growing across restarts
+	return &Hub{
+		lastId:      uint64(time.Now().UnixMicro()),
+		historySize: historySize,
+		subscribers: make(map[*Subscriber]struct{}),
+	}
+}
+
+func (h *Hub) Publish(session string, eventType string, chat string, data interface{}) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.lastId++
+	event := Event{
+		Id:        h.lastId,
+		Session:   session,
+		Type:      eventType,
+		Chat:      chat,
+		Timestamp: time.Now(),
+		Data:      data,
+	}
+
+	h.history = append(h.history, event)
+	if len(h.history) > h.historySize {
+		h.history = h.history[len(h.history)-h.historySize:]
+	}
+
+	for subscriber := range h.subscribers {
+		if !subscriber.filter.Matches(event) {
+			continue
+		}
+
+		select {
+		case subscriber.Events <- event:
+		default:
+			// drop subscribers that can't keep up, they can resume from their last event
+			h.remove(subscriber)
+		}
+	}
+}
+
+// Subscribe registers a subscriber, and returns the kept events
+// published after lastEventId that match the filter. When some of those events
+// are no longer kept, the returned events start with an EventResync.
+func (h *Hub) Subscribe(filter Filter, lastEventId uint64) (*Subscriber, []Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	subscriber := &Subscriber{
+		Events: make(chan Event, 64),
+		filter: filter,
+	}
+	h.subscribers[subscriber] = struct{}{}
+
+	var missed []Event
+	if lastEventId > 0 && h.lost(lastEventId) {
+		missed = append(missed, Event{Id: h.lastId, Type: EventResync, Timestamp: time.Now()})
+	}
+
+	if lastEventId > 0 {
+		for _, event := range h.history {
+			if event.Id > lastEventId && filter.Matches(event) {
+				missed = append(missed, event)
+			}
+		}
+	}
+
+	return subscriber, missed
+}
+
+// lost checks whether events published after lastEventId are missing from the history.
+// Ids from the future are from before a restart with a clock running ahead.
+func (h *Hub) lost(lastEventId uint64) bool {
+	if lastEventId >= h.lastId {
+		return lastEventId > h.lastId
+	}
+
+	return len(h.history) == 0 || lastEventId+1 < h.history[0].Id
+}
+
+func (h *Hub) Unsubscribe(subscriber *Subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.remove(subscriber)
+}
+
+func (h *Hub) remove(subscriber *Subscriber) {
+	if _, ok := h.subscribers[subscriber]; ok {
+		delete(h.subscribers, subscriber)
+		close(subscriber.Events)
+	}
+}
@@ -0,0 +1,46 @@
+package stream
+
+import "testing"
+
+func TestFilterMatches(t *testing.T) {
+	event := Event{Session: "a", Type: "qr", Chat: "628123456789@s.whatsapp.net"}
+
+	tests := []struct {
+		name    string
+		filter  Filter
+		matches bool
+	}{
+		{"empty", Filter{}, true},
+		{"session", Filter{Sessions: []string{"b", "a"}}, true},
+		{"other session", Filter{Sessions: []string{"b"}}, false},
+		{"other type", Filter{Types: []string{"message.received"}}, false},
+		{"excluded type", Filter{ExcludeTypes: []string{"qr"}}, false},
+		{"excluded type asked for", Filter{Types: []string{"qr"}, ExcludeTypes: []string{"qr"}}, false},
+		{"other type excluded", Filter{ExcludeTypes: []string{"presence"}}, true},
+	}
+
+	for _, test := range tests {
+		if matches := test.filter.Matches(event); matches != test.matches {
+			t.Errorf("%s: expected matches to be %t", test.name, test.matches)
+		}
+	}
+}
+
+func TestSubscribeExcludesMissedEvents(t *testing.T) {
+	hub := NewHub(10)
+	hub.Publish("a", "connection", "", nil)
+	hub.Publish("a", "qr", "", nil)
+
+	subscriber, missed := hub.Subscribe(Filter{ExcludeTypes: []string{"qr"}}, hub.history[0].Id-1)
+	defer hub.Unsubscribe(subscriber)
+
+	if len(missed) != 1 || missed[0].Type != "connection" {
+		t.Fatalf("expected only the connection event, got %v", missed)
+	}
+
+	hub.Publish("a", "qr", "", nil)
+	hub.Publish("a", "connection", "", nil)
+	if event := <-subscriber.Events; event.Type != "connection" {
+		t.Errorf("expected the qr event to be skipped, got %s", event.Type)
+	}
+}
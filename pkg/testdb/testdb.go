@@ -0,0 +1,29 @@
+// Package testdb opens in-memory message stores for tests.
+package testdb
+
+import (
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"testing"
+)
+
+// Open returns an in-memory sqlite database with the tables of the models,
+// closed when the test finishes.
+func Open(t testing.TB, models ...interface{}) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	// every connection to :memory: is a database of its own
+	db.DB().SetMaxOpenConns(1)
+
+	if err := db.AutoMigrate(models...).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
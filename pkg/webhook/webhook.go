@@ -0,0 +1,241 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/jinzhu/gorm"
+	"go.uber.org/zap"
+	"gomeow/cmd/models"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	HeaderEvent     = "X-Gomeow-Event"
+	HeaderDelivery  = "X-Gomeow-Delivery"
+	HeaderTimestamp = "X-Gomeow-Timestamp"
+	HeaderSignature = "X-Gomeow-Signature"
+)
+
+type Payload struct {
+	Id        string      `json:"id"`
+	Event     string      `json:"event"`
+	Timestamp time.Time   `json:"timestamp"`
+	Data      interface{} `json:"data"`
+}
+
+type Dispatcher struct {
+	db          *gorm.DB
+	client      *http.Client
+	maxAttempts int
+	backoff     time.Duration
+}
+
+func NewDispatcher(db *gorm.DB, maxAttempts int, backoff time.Duration, timeout time.Duration) *Dispatcher {
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	return &Dispatcher{
+		db:          db,
+		client:      &http.Client{Timeout: timeout},
+		maxAttempts: maxAttempts,
+		backoff:     backoff,
+	}
+}
+
+// Dispatch sends the event to every active webhook subscribed to it.
+// Deliveries run in the background.
+func (d *Dispatcher) Dispatch(event string, data interface{}) {
+	d.dispatch(event, data, nil)
+}
+
+// DispatchMessage sends a message event to the subscribed webhooks
+// whose chat type and keyword filters accept the message.
+func (d *Dispatcher) DispatchMessage(event string, data interface{}, isGroup bool, text string) {
+	d.dispatch(event, data, func(hook models.Webhook) bool {
+		return hook.Accepts(isGroup, text)
+	})
+}
+
+func (d *Dispatcher) dispatch(event string, data interface{}, accept func(hook models.Webhook) bool) {
+	var hooks []models.Webhook
+	if err := d.db.Where("active = ?", true).Find(&hooks).Error; err != nil {
+		zap.S().Errorf("Failed to load webhooks: %s", err)
+		return
+	}
+
+	for _, hook := range hooks {
+		if !hook.Subscribes(event) {
+			continue
+		}
+
+		if accept != nil && !accept(hook) {
+			continue
+		}
+
+		payload := Payload{
+			Id:        GenerateSecret(8),
+			Event:     event,
+			Timestamp: time.Now(),
+			Data:      data,
+		}
+
+		go d.deliver(hook, payload)
+	}
+}
+
+// deliver makes the first delivery attempt. Attempts that should be retried are
+// scheduled on their delivery row and picked up by RunRetries, so they survive a restart.
+func (d *Dispatcher) deliver(hook models.Webhook, payload Payload) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		zap.S().Errorf("Failed to encode %s webhook payload: %s", payload.Event, err)
+		return
+	}
+
+	d.attempt(hook, payload.Id, payload.Event, body, 1)
+}
+
+// RunRetries retries the failed deliveries whose backoff has passed, every few seconds.
+func (d *Dispatcher) RunRetries() {
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		d.retryDue()
+	}
+}
+
+func (d *Dispatcher) retryDue() {
+	var due []models.WebhookDelivery
+	err := d.db.
+		Where("next_attempt_at IS NOT NULL AND next_attempt_at <= ?", time.Now()).
+		Order("next_attempt_at").
+		Limit(100).
+		Find(&due).Error
+	if err != nil {
+		zap.S().Errorf("Failed to load webhook retries: %s", err)
+		return
+	}
+
+	for _, delivery := range due {
+		// lease the row, so a slow attempt isn't picked up again, and one interrupted
+		// by a restart is retried once the lease runs out
+		lease := time.Now().Add(d.client.Timeout + time.Minute)
+		claim := d.db.Model(&models.WebhookDelivery{}).
+			Where("id = ? AND next_attempt_at = ?", delivery.ID, *delivery.NextAttemptAt).
+			Update("next_attempt_at", lease)
+		if claim.Error != nil || claim.RowsAffected == 0 {
+			continue
+		}
+
+		go d.retry(delivery)
+	}
+}
+
+func (d *Dispatcher) retry(delivery models.WebhookDelivery) {
+	var hook models.Webhook
+	err := d.db.Where("id = ? AND active = ?", delivery.WebhookID, true).First(&hook).Error
+	if err == nil {
+		d.attempt(hook, delivery.DeliveryId, delivery.Event, []byte(delivery.Payload), delivery.Attempt+1)
+	}
+
+	// the next attempt, if any, is scheduled on its own row
+	if err := d.db.Model(&delivery).Update("next_attempt_at", gorm.Expr("NULL")).Error; err != nil {
+		zap.S().Errorf("Failed to finish webhook delivery %s retry: %s", delivery.DeliveryId, err)
+	}
+}
+
+// attempt makes a single delivery and logs it. If the delivery should be retried,
+// the next attempt is scheduled with exponential backoff.
+func (d *Dispatcher) attempt(hook models.Webhook, id string, event string, body []byte, attempt int) {
+	delivery := models.WebhookDelivery{
+		WebhookID:  hook.ID,
+		DeliveryId: id,
+		Event:      event,
+		Attempt:    attempt,
+		Payload:    string(body),
+	}
+
+	retry := false
+	defer func() {
+		if retry && attempt < d.maxAttempts {
+			// 1x, 2x, 4x, ... the configured backoff
+			next := time.Now().Add(d.backoff * time.Duration(1<<uint(attempt-1)))
+			delivery.NextAttemptAt = &next
+		}
+
+		if err := d.db.Create(&delivery).Error; err != nil {
+			zap.S().Errorf("Failed to log webhook delivery %s: %s", id, err)
+		}
+	}()
+
+	request, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		delivery.Error = err.Error()
+		return
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	request.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	request.Header.Set("User-Agent", "gomeow-webhook")
+	request.Header.Set(HeaderEvent, event)
+	request.Header.Set(HeaderDelivery, id)
+	request.Header.Set(HeaderTimestamp, timestamp)
+	request.Header.Set(HeaderSignature, "sha256="+Sign(hook.Secret, timestamp, body))
+
+	start := time.Now()
+	response, err := d.client.Do(request)
+	delivery.DurationMs = time.Since(start).Milliseconds()
+	if err != nil {
+		zap.S().Warnf("Webhook %d delivery %s attempt %d failed: %s", hook.ID, id, attempt, err)
+		delivery.Error = err.Error()
+		retry = true
+		return
+	}
+
+	_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, 64*1024))
+	_ = response.Body.Close()
+
+	delivery.StatusCode = response.StatusCode
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		delivery.Success = true
+		return
+	}
+
+	delivery.Error = fmt.Sprintf("unexpected status code %d", response.StatusCode)
+	zap.S().Warnf("Webhook %d delivery %s attempt %d failed: %s", hook.ID, id, attempt, delivery.Error)
+
+	// other client errors won't get better by retrying
+	retry = response.StatusCode >= 500 ||
+		response.StatusCode == http.StatusRequestTimeout ||
+		response.StatusCode == http.StatusTooManyRequests
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of "<timestamp>.<body>".
+func Sign(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// GenerateSecret returns a random hex string of the given byte length.
+func GenerateSecret(length int) string {
+	secret := make([]byte, length)
+	if _, err := rand.Read(secret); err != nil {
+		panic(err)
+	}
+
+	return hex.EncodeToString(secret)
+}